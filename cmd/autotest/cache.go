@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tanerincode/auto-test-generator/internal/cache"
+)
+
+// runCache implements the cache command. Without arguments it shows the size of the generation
+// cache.
+func runCache(fs *flag.FlagSet, args []string) {
+	root := fs.String("root", ".", "Root directory of the project")
+	olderThan := fs.Duration("older-than", 0, "With prune, only remove entries not used for this long, e.g. 720h (default all)")
+	lf := addLogFlags(fs)
+	action, err := parseAction(fs, args)
+	logger := lf.logger(os.Stderr)
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+
+	if *olderThan < 0 {
+		fatalf(logger, "older-than must not be negative")
+	}
+	if *olderThan > 0 && action != "prune" {
+		fatalf(logger, "-older-than only applies to cache prune")
+	}
+
+	c := cache.Open(*root)
+	dir := filepath.Join(*root, cache.CacheDir)
+
+	switch action {
+	case "":
+		entries, size, err := c.Stats()
+		if err != nil {
+			fatalf(logger, "failed to read cache: %v", err)
+		}
+		fmt.Printf("%s: %d entries, %s\n", dir, entries, formatSize(size))
+	case "prune":
+		removed, freed, err := c.Prune(*olderThan)
+		if err != nil {
+			fatalf(logger, "failed to prune cache: %v", err)
+		}
+		fmt.Printf("Removed %d entries from %s, freed %s\n", removed, dir, formatSize(freed))
+	default:
+		fatalf(logger, "unknown cache action: %s (must be prune)", action)
+	}
+}
+
+// parseAction parses args of a command taking an optional action, whose flags may come before or
+// after it, and returns the action, or "" if none was given.
+func parseAction(fs *flag.FlagSet, args []string) (string, error) {
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	action := fs.Arg(0)
+	if action == "" {
+		return "", nil
+	}
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return "", err
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("unexpected argument %q after %s", fs.Arg(0), action)
+	}
+	return action, nil
+}
+
+// formatSize formats a size in bytes for humans.
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", size)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestParseActionFlagsAfterAction(t *testing.T) {
+	fs := flag.NewFlagSet("cache", flag.ContinueOnError)
+	root := fs.String("root", ".", "")
+	olderThan := fs.Duration("older-than", 0, "")
+
+	action, err := parseAction(fs, []string{"prune", "-older-than", "720h", "-root", "proj"})
+	if err != nil {
+		t.Fatalf("parseAction: %v", err)
+	}
+	if action != "prune" {
+		t.Errorf("action = %q, want prune", action)
+	}
+	if *olderThan != 720*time.Hour {
+		t.Errorf("older-than = %s, want 720h", *olderThan)
+	}
+	if *root != "proj" {
+		t.Errorf("root = %q, want proj", *root)
+	}
+}
+
+func TestParseActionFlagsBeforeAction(t *testing.T) {
+	fs := flag.NewFlagSet("cache", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", 0, "")
+
+	action, err := parseAction(fs, []string{"-older-than", "1h", "prune"})
+	if err != nil {
+		t.Fatalf("parseAction: %v", err)
+	}
+	if action != "prune" || *olderThan != time.Hour {
+		t.Errorf("got action %q, older-than %s; want prune, 1h", action, *olderThan)
+	}
+}
+
+func TestParseActionRejectsExtraArguments(t *testing.T) {
+	fs := flag.NewFlagSet("cache", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Duration("older-than", 0, "")
+
+	if _, err := parseAction(fs, []string{"prune", "everything"}); err == nil {
+		t.Error("parseAction accepted an extra argument")
+	}
+	if _, err := parseAction(fs, []string{"prune", "-unknown"}); err == nil {
+		t.Error("parseAction accepted an unknown flag after the action")
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/tanerincode/auto-test-generator/internal/config"
+	"github.com/tanerincode/auto-test-generator/internal/exec"
+	"github.com/tanerincode/auto-test-generator/internal/gen"
+	"github.com/tanerincode/auto-test-generator/internal/output"
+)
+
+// runConfig implements the config command. Without arguments it shows every setting with
+// where its effective value comes from.
+func runConfig(fs *flag.FlagSet, args []string) {
+	root := fs.String("root", ".", "Root directory of the project")
+	lf := addLogFlags(fs)
+	fs.Parse(args)
+	logger := lf.logger(os.Stderr)
+
+	cfg, err := config.Load(*root)
+	if err != nil {
+		fatalf(logger, "failed to load config: %v", err)
+	}
+
+	switch fs.Arg(0) {
+	case "":
+		showConfig(cfg, *root)
+	case "get":
+		if fs.NArg() != 2 {
+			fatalf(logger, "usage: autotest config get <key>")
+		}
+		value, err := cfg.Get(fs.Arg(1))
+		if err != nil {
+			fatalf(logger, "%v", err)
+		}
+		fmt.Println(value)
+	case "set":
+		if fs.NArg() != 3 {
+			fatalf(logger, "usage: autotest config set <key> <value>")
+		}
+		if err := validateSetting(fs.Arg(1), fs.Arg(2)); err != nil {
+			fatalf(logger, "%v", err)
+		}
+		if err := cfg.Set(fs.Arg(1), fs.Arg(2)); err != nil {
+			fatalf(logger, "%v", err)
+		}
+		if err := config.Save(*root, cfg); err != nil {
+			fatalf(logger, "%v", err)
+		}
+		fmt.Printf("%s = %s\n", fs.Arg(1), fs.Arg(2))
+	default:
+		fatalf(logger, "unknown config action: %s (must be get or set)", fs.Arg(0))
+	}
+}
+
+// showConfig prints every setting's effective value and its source.
+func showConfig(cfg *config.Config, root string) {
+	fmt.Printf("Config file: %s\n\n", filepath.Join(root, config.FileName))
+
+	pm, source := cfg.PackageManager, "config"
+	if pm == "" {
+		pm, source = string(exec.DetectPackageManager(root, root)), "detected"
+	}
+	printSetting("packageManager", pm, source)
+
+	policy, source := cfg.WritePolicy, "config"
+	if policy == "" {
+		policy, source = string(output.Skip), "default"
+	}
+	printSetting("writePolicy", policy, source)
+
+	providers, source := cfg.Providers, "config"
+	if providers == "" {
+		providers, source = "auggie", "default"
+	}
+	printSetting("providers", providers, source)
+
+	baseURL, source := cfg.OpenAIBaseURL, "config"
+	if baseURL == "" {
+		baseURL, source = gen.DefaultOpenAIBaseURL, "default"
+	}
+	printSetting("openaiBaseURL", baseURL, source)
+
+	model, source := cfg.OpenAIModel, "config"
+	if model == "" {
+		model, source = gen.DefaultOpenAIModel, "default"
+	}
+	printSetting("openaiModel", model, source)
+
+	limits := []struct {
+		key         string
+		concurrency int
+		fallback    int
+		rpm         int
+	}{
+		{"auggie", cfg.AuggieConcurrency, gen.DefaultAuggieConcurrency, cfg.AuggieRPM},
+		{"openai", cfg.OpenAIConcurrency, gen.DefaultOpenAIConcurrency, cfg.OpenAIRPM},
+	}
+	for _, l := range limits {
+		concurrency, source := l.concurrency, "config"
+		if concurrency == 0 {
+			concurrency, source = l.fallback, "default"
+		}
+		printSetting(l.key+"Concurrency", strconv.Itoa(concurrency), source)
+
+		rpm, source := strconv.Itoa(l.rpm), "config"
+		if l.rpm == 0 {
+			rpm, source = "unlimited", "default"
+		}
+		printSetting(l.key+"RPM", rpm, source)
+	}
+
+	if len(cfg.Prices) == 0 {
+		printSetting("prices", "built-in", "default")
+	}
+	models := make([]string, 0, len(cfg.Prices))
+	for model := range cfg.Prices {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	for _, model := range models {
+		price := cfg.Prices[model]
+		printSetting("prices."+model, fmt.Sprintf("$%.2f prompt, $%.2f completion per 1M tokens", price.Prompt, price.Completion), "config")
+	}
+}
+
+// printSetting prints one line of showConfig.
+func printSetting(key string, value string, source string) {
+	fmt.Printf("%-17s = %s (%s)\n", key, value, source)
+}
+
+// validateSetting checks value for the setting key. An empty value unsets the setting.
+func validateSetting(key string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	var err error
+	switch key {
+	case "packageManager":
+		_, err = exec.ParsePackageManager(value)
+	case "writePolicy":
+		_, err = output.ParsePolicy(value)
+	case "providers":
+		_, err = parseProviders(value)
+	}
+	return err
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/tanerincode/auto-test-generator/internal/config"
+	"github.com/tanerincode/auto-test-generator/internal/exec"
+	"github.com/tanerincode/auto-test-generator/internal/framework"
+	"github.com/tanerincode/auto-test-generator/internal/gen"
+	"github.com/tanerincode/auto-test-generator/internal/scan"
+)
+
+// Check statuses. Warnings only matter for some flags, so they don't fail doctor.
+const (
+	statusPass = "pass"
+	statusWarn = "warn"
+	statusFail = "fail"
+)
+
+// check is the outcome of one doctor check.
+type check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// doctorReport is the doctor output for -json.
+type doctorReport struct {
+	OK     bool    `json:"ok"`
+	Checks []check `json:"checks"`
+}
+
+// runDoctor implements the doctor command. It checks every prerequisite of a generation run at
+// once and exits with status 1 if one fails.
+func runDoctor(fs *flag.FlagSet, args []string) {
+	root := fs.String("root", ".", "Root directory of the project")
+	out := fs.String("out", "", "Test root to check for writability, relative to -root (default -root)")
+	provider := fs.String("provider", "", "AI provider, or comma-separated fallback chain, to check: auggie, cursor, openai, offline; default the configured providers, or auggie")
+	jsonOut := fs.Bool("json", false, "Print the report as JSON")
+	lf := addLogFlags(fs)
+	fs.Parse(args)
+	logger := lf.logger(os.Stderr)
+
+	// A broken config file fails the package manager check below
+	cfg, err := config.Load(*root)
+	if err != nil {
+		cfg = &config.Config{}
+	}
+	providers, err := resolveProviders(*provider, cfg)
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+
+	pm, pmCheck := packageManagerCheck(*root)
+
+	checks := []check{
+		nodeCheck(),
+		pmCheck,
+	}
+	for _, name := range providers {
+		checks = append(checks, providerChecks(name, cfg, pm)...)
+	}
+	checks = append(checks, gitChecks(*root)...)
+	checks = append(checks, frameworkChecks(*root)...)
+	checks = append(checks, tscCheck(*root), writableCheck(filepath.Join(*root, *out)))
+
+	report := doctorReport{OK: true, Checks: checks}
+	for _, c := range checks {
+		if c.Status == statusFail {
+			report.OK = false
+		}
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fatalf(logger, "failed to encode report: %v", err)
+		}
+	} else {
+		printDoctor(report)
+	}
+
+	if !report.OK {
+		os.Exit(1)
+	}
+}
+
+// printDoctor prints a doctor report for humans, with the fix under each problem.
+func printDoctor(report doctorReport) {
+	failed, warned := 0, 0
+	for _, c := range report.Checks {
+		mark := "✓"
+		switch c.Status {
+		case statusWarn:
+			mark = "!"
+			warned++
+		case statusFail:
+			mark = "✗"
+			failed++
+		}
+		fmt.Printf("%s %-22s %s\n", mark, c.Name, c.Detail)
+		if c.Fix != "" && c.Status != statusPass {
+			fmt.Printf("  %-22s → %s\n", "", c.Fix)
+		}
+	}
+
+	switch {
+	case failed > 0:
+		fmt.Printf("\n%d check(s) failed, %d warning(s)\n", failed, warned)
+	case warned > 0:
+		fmt.Printf("\nAll required checks passed, %d warning(s)\n", warned)
+	default:
+		fmt.Println("\nAll checks passed")
+	}
+}
+
+// versionOf runs bin with --version and returns the first line of its output.
+func versionOf(bin string) (string, error) {
+	out, err := osexec.Command(bin, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return version, nil
+}
+
+// nodeCheck checks that Node.js is installed.
+func nodeCheck() check {
+	version, err := versionOf("node")
+	if err != nil {
+		return check{Name: "node", Status: statusFail, Detail: "node not found: " + err.Error(), Fix: "install Node.js from https://nodejs.org/"}
+	}
+	return check{Name: "node", Status: statusPass, Detail: version}
+}
+
+// packageManagerCheck checks that the project's package manager is installed and returns it.
+func packageManagerCheck(root string) (exec.PackageManager, check) {
+	cfg, err := config.Load(root)
+	if err != nil {
+		return exec.NPM, check{Name: "package manager", Status: statusFail, Detail: err.Error(), Fix: "fix or remove " + config.FileName}
+	}
+	pm, err := packageManager(cfg, root, root)
+	if err != nil {
+		return exec.NPM, check{Name: "package manager", Status: statusFail, Detail: err.Error(), Fix: "set packageManager in " + config.FileName + " to npm, pnpm, yarn, or bun"}
+	}
+
+	version, err := versionOf(string(pm))
+	if err != nil {
+		return pm, check{Name: "package manager", Status: statusFail, Detail: fmt.Sprintf("%s not found: %v", pm, err), Fix: fmt.Sprintf("install %s, or set packageManager in %s", pm, config.FileName)}
+	}
+	return pm, check{Name: "package manager", Status: statusPass, Detail: fmt.Sprintf("%s %s", pm, version)}
+}
+
+// providerChecks checks that the provider is set up: that its CLI is installed and runs,
+// or that its API key is set.
+func providerChecks(provider string, cfg *config.Config, pm exec.PackageManager) []check {
+	switch provider {
+	case "cursor":
+		return []check{{Name: "cursor", Status: statusPass, Detail: "nothing to check; Cursor falls back to basic generation"}}
+	case "offline":
+		return []check{{Name: "offline", Status: statusPass, Detail: "nothing to check; offline generation needs no setup"}}
+	case "openai":
+		openai := openAIConfig(cfg)
+		if openai.APIKey == "" {
+			return []check{{Name: "openai", Status: statusFail, Detail: gen.OpenAIKeyEnv + " is not set", Fix: "export " + gen.OpenAIKeyEnv + "=<your API key>"}}
+		}
+		return []check{{Name: "openai", Status: statusPass, Detail: fmt.Sprintf("%s at %s", openai.Model, openai.BaseURL)}}
+	}
+
+	version, err := gen.AuggieCLIVersion()
+	if err != nil {
+		return []check{
+			{Name: "auggie", Status: statusFail, Detail: err.Error(), Fix: "run: " + strings.Join(gen.AuggieInstallCommand(pm), " ")},
+			{Name: "auggie login", Status: statusFail, Detail: "auggie CLI not installed", Fix: "install auggie, then run: autotest login"},
+		}
+	}
+
+	// Auggie has no command reporting whether it is logged in, so only a failing CLI is certain
+	checks := []check{{Name: "auggie", Status: statusPass, Detail: version}}
+	if err := gen.CheckAuggieCLILogin(context.Background()); err != nil {
+		checks = append(checks, check{Name: "auggie login", Status: statusFail, Detail: err.Error(), Fix: "run: autotest login"})
+	} else {
+		checks = append(checks, check{Name: "auggie login", Status: statusWarn, Detail: "installed, login unverified", Fix: "if generation fails to authenticate, run: autotest login"})
+	}
+	return checks
+}
+
+// gitChecks checks the git repository, which the dirty check needs, and the default branch
+// -changed-only compares against.
+func gitChecks(root string) []check {
+	repoRoot, err := scan.RepoRoot(root)
+	if err != nil {
+		return []check{
+			{Name: "git repository", Status: statusFail, Detail: err.Error(), Fix: "run inside a git repository, or pass -allow-dirty to generate"},
+			{Name: "git base branch", Status: statusWarn, Detail: "no git repository", Fix: "-changed-only needs a git repository"},
+		}
+	}
+
+	checks := []check{{Name: "git repository", Status: statusPass, Detail: repoRoot}}
+	if ref, err := scan.BaseRef(repoRoot); err != nil {
+		checks = append(checks, check{Name: "git base branch", Status: statusWarn, Detail: err.Error(), Fix: "run: git fetch origin, or pass -base (only needed for -changed-only)"})
+	} else {
+		checks = append(checks, check{Name: "git base branch", Status: statusPass, Detail: ref})
+	}
+	return checks
+}
+
+// frameworkChecks detects the test framework of every project package the way generate does.
+func frameworkChecks(root string) []check {
+	ws, err := scan.LoadWorkspace(root)
+	if err != nil {
+		return []check{{Name: "workspace", Status: statusFail, Detail: err.Error(), Fix: "fix package.json or pnpm-workspace.yaml"}}
+	}
+
+	var checks []check
+	for _, pkg := range projectPackages(ws) {
+		name := "framework"
+		if ws.IsMonorepo() {
+			name = "framework (" + pkg.Name + ")"
+		}
+
+		detection, err := exec.DetectFramework(pkg.Dir)
+		if errors.Is(err, exec.ErrNoFramework) && ws.IsMonorepo() {
+			detection, err = exec.DetectFramework(ws.Root)
+		}
+
+		switch {
+		case errors.Is(err, exec.ErrAmbiguousFramework):
+			checks = append(checks, check{Name: name, Status: statusFail, Detail: err.Error(), Fix: "pass -fw to choose a framework"})
+		case errors.Is(err, exec.ErrNoFramework):
+			checks = append(checks, check{Name: name, Status: statusFail, Detail: err.Error(), Fix: "add one of " + strings.Join(framework.Names(), ", ") + " as a dev dependency, or pass -fw"})
+		case err != nil:
+			checks = append(checks, check{Name: name, Status: statusFail, Detail: err.Error(), Fix: "check " + filepath.Join(pkg.Dir, "package.json")})
+		default:
+			checks = append(checks, check{Name: name, Status: statusPass, Detail: fmt.Sprintf("%s (%s confidence, %s)", detection.Framework.Name, detection.Confidence, detection.Reason.Source)})
+		}
+	}
+	return checks
+}
+
+// tscCheck looks for the TypeScript compiler in the project's node_modules, then on PATH.
+// Generation doesn't need it, but running TypeScript tests usually does.
+func tscCheck(root string) check {
+	bin := filepath.Join(root, "node_modules", ".bin", "tsc")
+	if _, err := os.Stat(bin); err != nil {
+		bin = "tsc"
+	}
+
+	version, err := versionOf(bin)
+	if err != nil {
+		return check{Name: "tsc", Status: statusWarn, Detail: "tsc not found", Fix: "add typescript as a dev dependency"}
+	}
+	return check{Name: "tsc", Status: statusPass, Detail: version}
+}
+
+// writableCheck checks that test files can be created in dir, or in its nearest existing parent
+// when dir doesn't exist yet.
+func writableCheck(dir string) check {
+	existing := dir
+	for {
+		if info, err := os.Stat(existing); err == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+
+	f, err := os.CreateTemp(existing, ".autotest-doctor-*")
+	if err != nil {
+		return check{Name: "output directory", Status: statusFail, Detail: fmt.Sprintf("%s is not writable: %v", existing, err), Fix: "check the permissions of " + existing}
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	return check{Name: "output directory", Status: statusPass, Detail: dir + " is writable"}
+}
@@ -0,0 +1,821 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tanerincode/auto-test-generator/internal/cache"
+	"github.com/tanerincode/auto-test-generator/internal/config"
+	"github.com/tanerincode/auto-test-generator/internal/cost"
+	"github.com/tanerincode/auto-test-generator/internal/diff"
+	"github.com/tanerincode/auto-test-generator/internal/exec"
+	"github.com/tanerincode/auto-test-generator/internal/framework"
+	"github.com/tanerincode/auto-test-generator/internal/gen"
+	"github.com/tanerincode/auto-test-generator/internal/limit"
+	"github.com/tanerincode/auto-test-generator/internal/logging"
+	"github.com/tanerincode/auto-test-generator/internal/output"
+	"github.com/tanerincode/auto-test-generator/internal/progress"
+	"github.com/tanerincode/auto-test-generator/internal/report"
+	"github.com/tanerincode/auto-test-generator/internal/scan"
+	"github.com/tanerincode/auto-test-generator/internal/state"
+)
+
+// generateOptions are the validated flags of a generate run.
+type generateOptions struct {
+	root            string
+	forcedFramework framework.Framework
+	placement       scan.Placement
+	changes         *scan.ChangeSet // nil to scan every file
+	hunks           bool
+	files           []string // explicit source files instead of a scan, nil to scan
+	augment         bool
+	dryRun          bool
+	patchPath       string
+	maxWorkers      int
+	maxTestWorkers  int
+	validate        bool
+	fileTimeout     time.Duration
+	timeout         time.Duration
+	minCoverage     float64
+	allowDirty      bool
+	stage           bool
+	commit          bool
+	provider        string
+	retries         int
+	budget          *cost.Budget
+	noCache         bool
+	writePolicy     string
+	logs            *logging.Gate // what the logger writes to, held back while a prompt is open
+}
+
+// runGenerate implements the generate command, which is also what a flat-flag invocation runs.
+func runGenerate(fs *flag.FlagSet, args []string) {
+	pf := addProjectFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "Print plan and diffs without writing")
+	patchPath := fs.String("patch", "", "With -dry-run, also write the diffs to this file as a patch for git apply")
+	maxWorkers := fs.Int("max-workers", runtime.NumCPU(), "Maximum concurrent local workers, which read and analyze sources and merge tests; provider calls are limited in the provider settings")
+	maxTestWorkers := fs.Int("max-test-workers", 1, "Maximum packages whose tests run at once")
+	fileTimeout := fs.Duration("file-timeout", 10*time.Minute, "Give up on a file whose provider calls, with retries and fallbacks, take longer than this")
+	timeout := fs.Duration("timeout", 0, "Stop the whole run after this long, keeping completed results (0 for no limit)")
+	minCoverage := fs.Float64("min-coverage", 0, "Minimum coverage threshold (0-100); fail if below")
+	validate := fs.Bool("validate", false, "Check that generated tests are complete before writing them; invalid ones fail")
+	allowDirty := fs.Bool("allow-dirty", false, "Allow running with dirty working tree")
+	stageWritten := fs.Bool("stage", false, "Stage the written test files in git, e.g. from a pre-commit hook")
+	commitWritten := fs.Bool("commit", false, "Commit the written test files to a new autotest/<timestamp> branch, leaving the current branch as it is")
+	provider := fs.String("provider", "", "AI provider, or a comma-separated fallback chain such as auggie,openai,offline: auggie, cursor, openai, offline; default auggie")
+	retries := fs.Int("retries", 2, "Retries of a provider call that failed transiently, e.g. rate limited, before falling back")
+	bf := addBudgetFlags(fs)
+	noCache := fs.Bool("no-cache", false, "Generate every file again instead of reusing cached tests; new tests still refresh the cache")
+	writePolicy := fs.String("write-policy", "", "When a test file exists: skip, backup (copy to .bak, then overwrite), or interactive (review diffs); default skip")
+	reportFormat := fs.String("report", "", "Write a run report: json, junit, or sarif")
+	reportOut := fs.String("report-out", "", "Report file (default autotest-report.json, .xml or .sarif)")
+	lf := addLogFlags(fs)
+	fs.Parse(args)
+
+	// On a terminal, logs go through the progress line so it stays below them
+	term := lf.terminal()
+	var logOut io.Writer = os.Stderr
+	if term != nil {
+		logOut = term
+	}
+	logs := logging.NewGate(logOut)
+	logger := lf.logger(logs)
+
+	// A command name after flags means the command was given too late
+	if fs.NArg() > 0 && filepath.Ext(fs.Arg(0)) == "" {
+		fatalf(logger, "unexpected argument %q; commands go first, e.g. autotest %s -root <path>", fs.Arg(0), fs.Arg(0))
+	}
+
+	// Check if -root was explicitly provided
+	rootProvided := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "root" {
+			rootProvided = true
+		}
+	})
+
+	if !rootProvided {
+		fatalf(logger, "-root flag is required, e.g. autotest generate -root ./my-project -allow-dirty (see autotest help)")
+	}
+
+	// Validate flags
+	forcedFramework, err := pf.forcedFramework()
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+	if *minCoverage < 0 || *minCoverage > 100 {
+		fatalf(logger, "min-coverage must be between 0 and 100")
+	}
+	if *maxWorkers < 1 {
+		fatalf(logger, "max-workers must be at least 1")
+	}
+	if *maxTestWorkers < 1 {
+		fatalf(logger, "max-test-workers must be at least 1")
+	}
+	if *fileTimeout <= 0 {
+		fatalf(logger, "file-timeout must be positive")
+	}
+	if *timeout < 0 {
+		fatalf(logger, "timeout must not be negative")
+	}
+	if *provider != "" {
+		if _, err := parseProviders(*provider); err != nil {
+			fatalf(logger, "%v", err)
+		}
+	}
+	if *retries < 0 {
+		fatalf(logger, "retries must not be negative")
+	}
+	budget, err := bf.budget()
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+	changes, err := pf.changeSet()
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+	if fs.NArg() > 0 && changes != nil {
+		fatalf(logger, "-changed-only, -base and -staged cannot be used with source files")
+	}
+	files, err := sourceFiles(*pf.root, fs.Args())
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+	if *patchPath != "" && !*dryRun {
+		fatalf(logger, "-patch requires -dry-run")
+	}
+	if *stageWritten && *dryRun {
+		fatalf(logger, "-stage cannot be used with -dry-run")
+	}
+	if *commitWritten && (*dryRun || *stageWritten) {
+		fatalf(logger, "-commit cannot be used with -dry-run or -stage")
+	}
+
+	var format report.Format
+	if *reportFormat != "" {
+		if format, err = report.ParseFormat(*reportFormat); err != nil {
+			fatalf(logger, "%v", err)
+		}
+	} else if *reportOut != "" {
+		fatalf(logger, "-report-out requires -report")
+	}
+
+	testPlacement, err := pf.testPlacement()
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+
+	opts := generateOptions{
+		root:            *pf.root,
+		forcedFramework: forcedFramework,
+		placement:       testPlacement,
+		changes:         changes,
+		hunks:           *pf.hunks,
+		files:           files,
+		augment:         pf.augment(),
+		dryRun:          *dryRun,
+		patchPath:       *patchPath,
+		maxWorkers:      *maxWorkers,
+		maxTestWorkers:  *maxTestWorkers,
+		validate:        *validate,
+		fileTimeout:     *fileTimeout,
+		timeout:         *timeout,
+		minCoverage:     *minCoverage,
+		allowDirty:      *allowDirty,
+		stage:           *stageWritten,
+		commit:          *commitWritten,
+		provider:        *provider,
+		retries:         *retries,
+		budget:          budget,
+		noCache:         *noCache,
+		writePolicy:     *writePolicy,
+		logs:            logs,
+	}
+
+	// Ctrl-C and -timeout stop the run, but completed results are still written and reported
+	ctx, stop := interruptContext(logger)
+	defer stop()
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, opts.timeout, fmt.Errorf("run timed out after %s", opts.timeout))
+		defer cancel()
+	}
+
+	// The report is written whether or not the run succeeds
+	rep := report.New(opts.root)
+	runErr := generate(ctx, logger, term, opts, rep)
+
+	if format != "" {
+		path := *reportOut
+		if path == "" {
+			path = reportFileName(format)
+		}
+		if err := rep.Write(path, format); err != nil {
+			logger.Error(err.Error())
+		} else {
+			logger.Info("wrote report", "file", path, "format", format)
+		}
+	}
+
+	if runErr != nil {
+		fatalf(logger, "%v", runErr)
+	}
+}
+
+// sourceFiles returns the source files given as arguments, relative to root, or nil if none
+// were given. Given files get tests whether or not they have some already.
+func sourceFiles(root string, args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		path := filepath.Join(root, arg)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source file: %w", err)
+		}
+		ext := filepath.Ext(path)
+		if info.IsDir() || (ext != ".ts" && ext != ".tsx") || strings.HasSuffix(path, ".d.ts") {
+			return nil, fmt.Errorf("invalid source file: %s (must be a .ts or .tsx file)", arg)
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// reportFileName returns the default report file name for format.
+func reportFileName(format report.Format) string {
+	switch format {
+	case report.JUnit:
+		return "autotest-report.xml"
+	case report.SARIF:
+		return "autotest-report.sarif"
+	default:
+		return "autotest-report.json"
+	}
+}
+
+// generate runs test generation and records every file in rep. Progress is drawn on term, or
+// logged if term is nil. When ctx is done, no new files are started and running provider calls
+// are killed, but files generated so far are still written.
+func generate(ctx context.Context, logger *slog.Logger, term *progress.Terminal, opts generateOptions, rep *report.Report) error {
+	root := opts.root
+
+	cfg, err := config.Load(root)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	rootPM, err := packageManager(cfg, root, root)
+	if err != nil {
+		return err
+	}
+
+	policyName := opts.writePolicy
+	if policyName == "" {
+		policyName = cfg.WritePolicy
+	}
+	if policyName == "" {
+		policyName = string(output.Skip)
+	}
+	policy, err := output.ParsePolicy(policyName)
+	if err != nil {
+		return err
+	}
+
+	providers, err := resolveProviders(opts.provider, cfg)
+	if err != nil {
+		return err
+	}
+	if err := checkPriced(providers, cfg, opts.budget); err != nil {
+		return err
+	}
+
+	// Check git status unless --allow-dirty
+	if !opts.allowDirty {
+		dirty, err := scan.IsWorkingTreeDirty(root)
+		if err != nil {
+			return fmt.Errorf("failed to check git status: %w", err)
+		}
+		if dirty {
+			return errors.New("working tree is dirty; commit changes or use --allow-dirty")
+		}
+	}
+
+	// Discover workspace packages
+	ws, err := scan.LoadWorkspace(root)
+	if err != nil {
+		return fmt.Errorf("failed to load workspace: %w", err)
+	}
+	if ws.IsMonorepo() {
+		logger.Info("found workspace packages", "count", len(ws.Packages))
+	}
+
+	// Scan for files needing tests, unless they were given
+	candidates := opts.files
+	var lines map[string][]diff.Range
+	var tests map[string]string
+	if candidates != nil {
+		if tests, err = recordedTests(root, candidates); err != nil {
+			return err
+		}
+	} else {
+		candidates, lines, err = findCandidates(root, ws, opts.changes, opts.hunks, opts.placement, opts.augment)
+		if err != nil {
+			return fmt.Errorf("failed to scan files: %w", err)
+		}
+	}
+
+	if len(candidates) == 0 {
+		logger.Info("no files need tests")
+		return nil
+	}
+
+	logger.Info("found files needing tests", "count", len(candidates))
+
+	// Detect framework per package, falling back to the workspace root for hoisted dev dependencies
+	frameworks := detectFrameworks(logger, ws, candidatePackages(ws, candidates), opts.forcedFramework)
+
+	if len(frameworks) == 0 {
+		return errors.New("failed to detect framework")
+	}
+
+	// Setup AI providers
+	chain, err := setupProviders(logger, providers, cfg, rootPM)
+	if err != nil {
+		return err
+	}
+	// Local work shares -max-workers, while provider calls are limited by each provider's settings
+	local := limit.New(opts.maxWorkers, 0)
+	chain.retries = opts.retries
+	chain.timeout = opts.fileTimeout
+	chain.local = local
+	chain.budget = opts.budget
+	chain.cache = cache.Open(root)
+	chain.readCache = !opts.noCache
+
+	// Build work queue
+	workQueue := buildWorkQueue(logger, root, ws, candidates, frameworks, opts.placement, opts.augment, lines, tests, local)
+
+	// Set up writing before any provider is called, so an unreadable state file wastes no calls.
+	// Each result is written as it arrives, and each package tested as soon as all of its files are in
+	var writer *output.Writer
+	var batches chan testBatch
+	var testOutcomes <-chan map[string]error
+	var manifest *state.State
+	if !opts.dryRun {
+		if manifest, err = state.Load(root); err != nil {
+			return err
+		}
+		writer = output.NewWriter(policy)
+		// Reviews and test runs take turns on the terminal, and logs wait for reviews
+		var take func() func()
+		if policy == output.Interactive {
+			writer.Hold = opts.logs.Hold
+			take = opts.logs.Take
+		}
+		batches = make(chan testBatch, len(ws.Packages))
+		testOutcomes = runTestStage(ctx, logger, cfg, ws, frameworks, batches, opts.maxTestWorkers, take)
+	}
+
+	// Interactive review prompts on the terminal, where a progress line would get in its way
+	if policy == output.Interactive {
+		term = nil
+	}
+
+	// Reaching the budget stops new files from starting, like Ctrl-C, but running ones finish
+	sched, stopScheduling := context.WithCancelCause(ctx)
+	defer stopScheduling(nil)
+
+	// Process with worker pool
+	results := make(chan gen.TestResult, chain.slots())
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, chain.slots())
+	tracker := progress.NewTracker(len(workQueue))
+	stopProgress := progress.Watch(tracker, term, logger)
+
+	for _, item := range workQueue {
+		wg.Add(1)
+		go func(wi workItem) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			tracker.Start(wi.relPath)
+			var result gen.TestResult
+			if sched.Err() != nil {
+				result = gen.TestResult{SourcePath: wi.path, TestPath: wi.testPath, Error: &report.Error{Category: report.CategoryCanceled, Err: context.Cause(sched)}}
+			} else {
+				result = generateOne(ctx, logger, wi, chain, local)
+			}
+			if err := opts.budget.Err(); err != nil {
+				stopScheduling(err)
+			}
+			tracker.Finish(wi.relPath, result.Error != nil)
+			results <- result
+		}(item)
+	}
+
+	go func() {
+		wg.Wait()
+		stopProgress()
+		close(results)
+	}()
+
+	stage := newWriteStage(ctx, logger, root, ws, rep, writer, manifest, opts.validate, workQueue, batches)
+	for result := range results {
+		stage.record(result)
+	}
+	if manifest != nil {
+		if err := manifest.Save(root); err != nil {
+			logger.Warn("failed to save generated test state", "error", err)
+		}
+	}
+
+	if stage.canceled > 0 {
+		logger.Warn("files not generated", "count", stage.canceled, "reason", context.Cause(sched))
+	}
+	logger.Info("generated tests", "count", stage.generated, "failed", stage.failed)
+	tokens, spent := opts.budget.Spent()
+	logger.Info("provider usage", "tokens", tokens, "cost", cost.Format(spent))
+
+	// The run stopped short if it was interrupted, or if the budget left files out
+	stopped := func() error {
+		if err := stopCause(ctx); err != nil || stage.canceled == 0 {
+			return err
+		}
+		return context.Cause(sched)
+	}
+
+	var testErrs map[string]error
+	if batches != nil {
+		close(batches)
+		testErrs = <-testOutcomes
+		logger.Info("wrote test files", "count", stage.writtenCount)
+	}
+
+	if opts.stage && stage.writtenCount > 0 {
+		var paths []string
+		for _, written := range stage.written {
+			paths = append(paths, written...)
+		}
+		if err := scan.Stage(root, paths); err != nil {
+			return fmt.Errorf("failed to stage test files: %w", err)
+		}
+		logger.Info("staged test files", "count", len(paths))
+	}
+
+	if opts.commit && stage.writtenCount > 0 {
+		if err := commitTests(logger, root, stage, rep); err != nil {
+			return err
+		}
+	}
+
+	if stage.generated == 0 {
+		if err := stopped(); err != nil {
+			return err
+		}
+		if stage.failed > 0 {
+			return errors.New("all generations failed")
+		}
+		logger.Info("no tests generated")
+		return nil
+	}
+
+	// Dry-run: print plan and diffs
+	if opts.dryRun {
+		if err := printDryRun(stage.planned, policy, root, opts.patchPath); err != nil {
+			return err
+		}
+		return stopped()
+	}
+
+	for i, entry := range rep.Entries {
+		if entry.Outcome != output.Created.String() && entry.Outcome != output.Updated.String() {
+			continue
+		}
+		pkgDir := ws.PackageFor(filepath.Join(root, entry.Source)).Dir
+		if err, ran := testErrs[pkgDir]; ran {
+			rep.Entries[i].Tests = report.TestsPassed
+			if err != nil {
+				rep.Entries[i].Tests = report.TestsFailed
+			}
+		}
+	}
+
+	// An interrupted or over-budget run keeps what it wrote, but doesn't measure coverage
+	if err := stopped(); err != nil {
+		return err
+	}
+
+	// Check coverage if requested
+	if opts.minCoverage > 0 {
+		coverage, err := checkCoverage(ctx, logger, cfg, ws, frameworks, opts.minCoverage)
+		for i, entry := range rep.Entries {
+			pkgDir := ws.PackageFor(filepath.Join(root, entry.Source)).Dir
+			if c, ok := coverage[pkgDir]; ok {
+				rep.Entries[i].Coverage = &c
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := stopCause(ctx); err != nil {
+		return err
+	}
+
+	logger.Info("done")
+	return nil
+}
+
+// recordedTests returns the test files recorded in the manifest for sources, by source path, so
+// given files keep the test file they were generated into, whatever the placement.
+func recordedTests(root string, sources []string) (map[string]string, error) {
+	st, err := state.Load(root)
+	if err != nil {
+		return nil, err
+	}
+	tests := make(map[string]string)
+	for _, source := range sources {
+		rel, err := filepath.Rel(root, source)
+		if err != nil {
+			continue
+		}
+		if record, ok := st.Files[rel]; ok {
+			tests[source] = filepath.Join(root, record.Test)
+		}
+	}
+	return tests, nil
+}
+
+// commitTests commits the test files the run wrote to a new autotest/<timestamp> branch, with a
+// message listing their sources.
+func commitTests(logger *slog.Logger, root string, stage *writeStage, rep *report.Report) error {
+	var paths []string
+	for _, written := range stage.written {
+		paths = append(paths, written...)
+	}
+	var sources []string
+	for _, entry := range rep.Entries {
+		if entry.Outcome == output.Created.String() || entry.Outcome == output.Updated.String() {
+			sources = append(sources, entry.Source)
+		}
+	}
+	slices.Sort(sources)
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "Add generated tests for %d source file(s)\n\nCovers:\n", len(sources))
+	for _, source := range sources {
+		fmt.Fprintf(&msg, "- %s\n", filepath.ToSlash(source))
+	}
+
+	branch := "autotest/" + time.Now().Format("20060102-150405")
+	hash, err := scan.CommitToBranch(root, branch, msg.String(), paths)
+	if err != nil {
+		return fmt.Errorf("failed to commit test files: %w", err)
+	}
+	logger.Info("committed test files", "branch", branch, "commit", hash.String()[:7], "count", len(paths))
+	return nil
+}
+
+// stopCause returns why ctx is done, or nil if it isn't.
+func stopCause(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(ctx)
+}
+
+// generateOne generates the tests of a single work item with the provider chain, then merges them
+// once local allows. If ctx is done first, the result has a CategoryCanceled error.
+func generateOne(ctx context.Context, logger *slog.Logger, wi workItem, chain *providerChain, local *limit.Limiter) (result gen.TestResult) {
+	start := time.Now()
+	result = gen.TestResult{
+		SourcePath: wi.path,
+		TestPath:   wi.testPath,
+		SourceHash: state.Hash(wi.code),
+	}
+	defer func() { result.Duration = time.Since(start) }()
+
+	if ctx.Err() != nil {
+		result.Error = &report.Error{Category: report.CategoryCanceled, Err: context.Cause(ctx)}
+		return result
+	}
+
+	testCode, err := chain.generate(ctx, logger, wi, &result)
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		result.Error = &report.Error{Category: report.CategoryCanceled, Err: context.Cause(ctx)}
+		return result
+	default:
+		result.Error = &report.Error{Category: report.CategoryProvider, Err: fmt.Errorf("generation failed: %w", err)}
+		return result
+	}
+
+	if wi.focus != nil {
+		if err := local.Acquire(ctx); err != nil {
+			result.Error = &report.Error{Category: report.CategoryCanceled, Err: context.Cause(ctx)}
+			return result
+		}
+		defer local.Release()
+		testCode, err = gen.MergeTests(wi.code, wi.focus.Existing, testCode, wi.focus.Exports, wi.focus.Changed)
+		if err != nil {
+			result.Error = &report.Error{Category: report.CategoryMerge, Err: fmt.Errorf("merge failed: %w", err)}
+			return result
+		}
+		result.Augmented = wi.focus.Exports
+	}
+
+	result.TestCode = testCode
+	return result
+}
+
+// reportEntry returns the report entry of a generation result, with paths relative to root.
+func reportEntry(root string, ws *scan.Workspace, result gen.TestResult, outcome string) report.Entry {
+	source, _ := filepath.Rel(root, result.SourcePath)
+	test, _ := filepath.Rel(root, result.TestPath)
+
+	entry := report.Entry{
+		Source:     source,
+		Test:       test,
+		Package:    ws.PackageFor(result.SourcePath).Name,
+		Provider:   result.Provider,
+		Attempts:   result.Attempts,
+		Cached:     result.Cached,
+		DurationMS: result.Duration.Milliseconds(),
+		Outcome:    outcome,
+
+		PromptTokens:     result.Usage.PromptTokens,
+		CompletionTokens: result.Usage.CompletionTokens,
+		TokensEstimated:  result.Usage.Estimated,
+		Cost:             result.Cost,
+	}
+	if result.Error != nil {
+		entry.Error = result.Error.Error()
+		entry.ErrorCategory = report.CategoryOf(result.Error)
+	}
+	return entry
+}
+
+// runTests runs the given test files of every package in testPaths (keyed by package directory)
+// with the package's own test script. A package with no test files runs its whole suite.
+// It returns the outcome of every package that was run, nil for passing ones, and stops when
+// ctx is done.
+func runTests(ctx context.Context, logger *slog.Logger, cfg *config.Config, ws *scan.Workspace, frameworks map[string]framework.Framework, testPaths map[string][]string) map[string]error {
+	outcomes := make(map[string]error)
+	for _, pkg := range ws.Packages {
+		if ctx.Err() != nil {
+			break
+		}
+		paths, ok := testPaths[pkg.Dir]
+		if !ok {
+			continue
+		}
+		fw, ok := frameworks[pkg.Dir]
+		if !ok {
+			continue
+		}
+
+		pm, err := packageManager(cfg, pkg.Dir, ws.Root)
+		if err != nil {
+			logger.Warn("skipping tests", "package", pkg.Name, "error", err)
+			continue
+		}
+
+		logger.Info("running tests", "package", pkg.Name, "packageManager", string(pm), "files", len(paths))
+		err = exec.RunTests(ctx, paths, fw, pkg.Dir, pm)
+		if ctx.Err() != nil {
+			// A killed run says nothing about the tests
+			break
+		}
+		if err != nil {
+			logger.Warn("test run failed", "package", pkg.Name, "error", err)
+		}
+		outcomes[pkg.Dir] = err
+	}
+
+	return outcomes
+}
+
+// checkCoverage measures the coverage of every package in frameworks, keyed by package
+// directory. It returns an error if one is below minCoverage.
+func checkCoverage(ctx context.Context, logger *slog.Logger, cfg *config.Config, ws *scan.Workspace, frameworks map[string]framework.Framework, minCoverage float64) (map[string]float64, error) {
+	logger.Info("checking coverage", "minimum", minCoverage)
+
+	coverages := make(map[string]float64)
+	var below []string
+	for _, pkg := range ws.Packages {
+		fw, ok := frameworks[pkg.Dir]
+		if !ok {
+			continue
+		}
+
+		pm, err := packageManager(cfg, pkg.Dir, ws.Root)
+		if err != nil {
+			return coverages, err
+		}
+
+		if ctx.Err() != nil {
+			return coverages, context.Cause(ctx)
+		}
+		coverage, err := exec.GetCoverage(ctx, pkg.Dir, fw, pm)
+		if err != nil {
+			logger.Warn("failed to get coverage", "package", pkg.Name, "error", err)
+			continue
+		}
+		coverages[pkg.Dir] = coverage
+
+		if coverage < minCoverage {
+			logger.Warn("coverage below minimum", "package", pkg.Name, "coverage", fmt.Sprintf("%.1f%%", coverage))
+			below = append(below, fmt.Sprintf("%s %.1f%%", pkg.Name, coverage))
+			continue
+		}
+		logger.Info("coverage", "package", pkg.Name, "coverage", fmt.Sprintf("%.1f%%", coverage))
+	}
+
+	if len(below) > 0 {
+		return coverages, fmt.Errorf("coverage below minimum %.1f%%: %s", minCoverage, strings.Join(below, ", "))
+	}
+	return coverages, nil
+}
+
+// printDryRun prints every result with a unified diff against the file on disk. With patchPath
+// set, the diffs are also written there as one patch to apply with `git apply` from the
+// repository root (or from root outside a git repository).
+func printDryRun(results []gen.TestResult, policy output.Policy, root string, patchPath string) error {
+	base, err := scan.RepoRoot(root)
+	if err != nil {
+		if base, err = filepath.Abs(root); err != nil {
+			return err
+		}
+	}
+
+	color := output.IsTerminal(os.Stdout)
+	var patch strings.Builder
+
+	fmt.Println("\n=== DRY RUN: Test Generation Plan ===")
+	for _, result := range results {
+		old, err := os.ReadFile(result.TestPath)
+		exists := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", result.TestPath, err)
+		}
+
+		fmt.Printf("\nSource: %s\n", result.SourcePath)
+		fmt.Printf("Test:   %s\n", result.TestPath)
+		if len(result.Augmented) > 0 {
+			fmt.Printf("Adds:   %s\n", strings.Join(result.Augmented, ", "))
+		}
+
+		if exists && policy == output.Skip && len(result.Augmented) == 0 {
+			fmt.Println("Status: exists, would be skipped (see -write-policy)")
+			continue
+		}
+
+		added, removed := diff.Stats(string(old), result.TestCode)
+		fmt.Printf("Lines:  %d (+%d -%d)\n", diff.LineCount(result.TestCode), added, removed)
+
+		abs, err := filepath.Abs(result.TestPath)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, abs)
+		if err != nil {
+			return err
+		}
+
+		d := diff.GitFile(rel, string(old), result.TestCode, !exists)
+		if d == "" {
+			fmt.Println("Status: unchanged")
+			continue
+		}
+		patch.WriteString(d)
+
+		if color {
+			d = diff.Colorize(d)
+		}
+		fmt.Printf("\n%s", d)
+	}
+
+	if patchPath != "" {
+		if err := output.WriteFileAtomic(patchPath, []byte(patch.String()), 0644); err != nil {
+			return fmt.Errorf("failed to write patch %s: %w", patchPath, err)
+		}
+		abs, _ := filepath.Abs(patchPath)
+		fmt.Printf("\nWrote patch to %s (apply from %s with: git apply %s)\n", patchPath, base, abs)
+	}
+
+	fmt.Println("\n(No files written in dry-run mode)")
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tanerincode/auto-test-generator/internal/scan"
+)
+
+// hookMarker identifies the hooks install-hook wrote, which it replaces without -force.
+const hookMarker = "# Installed by autotest install-hook"
+
+// runInstallHook implements the install-hook command. It writes a git hook that checks that the
+// changed TypeScript files have tests: the staged ones before a commit, or those of the branch
+// before a push.
+func runInstallHook(fs *flag.FlagSet, args []string) {
+	root := fs.String("root", ".", "Root directory of the project")
+	hook := fs.String("hook", "pre-commit", "Hook to install: pre-commit (checks the staged files) or pre-push (checks the files changed on the branch)")
+	onMissing := fs.String("on-missing", "fail", "When changed files have no tests: fail (stop the commit or push), warn, or generate (generate their tests and stage them; pre-commit only)")
+	base := fs.String("base", "", "With pre-push, the branch, tag or commit to compare against (default origin/main, or origin/master)")
+	force := fs.Bool("force", false, "Replace an existing hook that autotest did not install")
+	lf := addLogFlags(fs)
+	fs.Parse(args)
+	logger := lf.logger(os.Stderr)
+
+	if *hook != "pre-commit" && *hook != "pre-push" {
+		fatalf(logger, "invalid hook: %s (must be pre-commit or pre-push)", *hook)
+	}
+	switch {
+	case *onMissing != "fail" && *onMissing != "warn" && *onMissing != "generate":
+		fatalf(logger, "invalid on-missing: %s (must be fail, warn or generate)", *onMissing)
+	case *onMissing == "generate" && *hook != "pre-commit":
+		fatalf(logger, "-on-missing generate only works in a pre-commit hook, where the tests can still join the commit")
+	case *base != "" && *hook != "pre-push":
+		fatalf(logger, "-base only applies to pre-push hooks; pre-commit hooks check the staged files")
+	}
+
+	// Hooks run from the top of the working tree, so the project is given relative to it
+	repoRoot, err := scan.RepoRoot(*root)
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+	absRoot, err := filepath.Abs(*root)
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+	projectRoot, err := filepath.Rel(repoRoot, absRoot)
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fatalf(logger, "failed to find the autotest binary: %v", err)
+	}
+
+	dir, err := scan.HooksDir(*root)
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+	path := filepath.Join(dir, *hook)
+	if existing, err := os.ReadFile(path); err == nil && !strings.Contains(string(existing), hookMarker) && !*force {
+		fatalf(logger, "%s already exists and was not installed by autotest; merge it by hand or use -force to replace it", path)
+	}
+
+	script := hookScript(exe, projectRoot, *hook, *onMissing, *base)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fatalf(logger, "failed to create %s: %v", dir, err)
+	}
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		fatalf(logger, "failed to write hook: %v", err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, 0755); err != nil {
+		fatalf(logger, "failed to make hook executable: %v", err)
+	}
+
+	fmt.Printf("Installed %s hook: %s\n", *hook, path)
+}
+
+// hookScript returns the hook script running exe on the project at root, relative to the top of
+// the working tree.
+func hookScript(exe string, root string, hook string, onMissing string, base string) string {
+	changes := []string{"-staged"}
+	subject := "staged"
+	if hook == "pre-push" {
+		changes = []string{"-changed-only"}
+		if base != "" {
+			changes = []string{"-base", shellQuote(base)}
+		}
+		subject = "changed"
+	}
+
+	// Whole test files are what is missing; changed exports of tested files are left to generate
+	var command, summary string
+	switch onMissing {
+	case "generate":
+		command = fmt.Sprintf("%s generate -root %s %s -hunks=false -allow-dirty -stage -q", shellQuote(exe), shellQuote(root), strings.Join(changes, " "))
+		summary = "Generates and stages tests for the " + subject + " TypeScript files that have none."
+	default:
+		command = fmt.Sprintf("%s plan -root %s %s -hunks=false -on-missing %s -q", shellQuote(exe), shellQuote(root), strings.Join(changes, " "), onMissing)
+		summary = "Checks that the " + subject + " TypeScript files have tests (" + onMissing + " if not)."
+	}
+
+	return fmt.Sprintf("#!/bin/sh\n%s; delete this file to remove it.\n# %s\nexec %s\n", hookMarker, summary, command)
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
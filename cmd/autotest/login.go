@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/tanerincode/auto-test-generator/internal/config"
+	"github.com/tanerincode/auto-test-generator/internal/gen"
+)
+
+// runLogin implements the login command.
+func runLogin(fs *flag.FlagSet, args []string) {
+	root := fs.String("root", ".", "Root directory of the project, used to pick the package manager")
+	provider := fs.String("provider", "auggie", "AI provider: auggie, cursor, openai, offline")
+	lf := addLogFlags(fs)
+	fs.Parse(args)
+	logger := lf.logger(os.Stderr)
+
+	switch *provider {
+	case "auggie":
+		cfg, err := config.Load(*root)
+		if err != nil {
+			fatalf(logger, "failed to load config: %v", err)
+		}
+		pm, err := packageManager(cfg, *root, *root)
+		if err != nil {
+			fatalf(logger, "%v", err)
+		}
+		if err := gen.LoginToAuggie(logger, pm); err != nil {
+			fatalf(logger, "Login failed: %v", err)
+		}
+	case "cursor":
+		logger.Info("Cursor needs no login here; sign in from the Cursor IDE")
+	case "openai":
+		logger.Info("the openai provider needs no login; set its API key in " + gen.OpenAIKeyEnv)
+	case "offline":
+		logger.Info("the offline provider needs no login")
+	default:
+		fatalf(logger, "invalid provider: %s (must be auggie, cursor, openai or offline)", *provider)
+	}
+}
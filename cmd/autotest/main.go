@@ -1,281 +1,347 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"runtime"
-	"sync"
+	"strings"
+	"syscall"
 
+	"github.com/tanerincode/auto-test-generator/internal/config"
+	"github.com/tanerincode/auto-test-generator/internal/cost"
 	"github.com/tanerincode/auto-test-generator/internal/exec"
-	"github.com/tanerincode/auto-test-generator/internal/gen"
+	"github.com/tanerincode/auto-test-generator/internal/framework"
+	"github.com/tanerincode/auto-test-generator/internal/logging"
+	"github.com/tanerincode/auto-test-generator/internal/output"
+	"github.com/tanerincode/auto-test-generator/internal/progress"
 	"github.com/tanerincode/auto-test-generator/internal/scan"
 )
 
+// command is a subcommand with its own flags.
+type command struct {
+	name    string
+	args    string // positional arguments shown in the usage line
+	summary string
+	run     func(fs *flag.FlagSet, args []string)
+}
+
+// commands lists the subcommands in the order help shows them.
+var commands = []command{
+	{name: "generate", args: "[source files...]", summary: "Generate tests for a project (the default when only flags are given)", run: runGenerate},
+	{name: "plan", summary: "Show what would be generated, without calling a provider", run: runPlan},
+	{name: "run", args: "[test files...]", summary: "Run the project's tests, or only the given test files", run: runRun},
+	{name: "coverage", summary: "Measure test coverage, optionally failing below a minimum", run: runCoverage},
+	{name: "login", summary: "Log in to the AI provider (one time setup)", run: runLogin},
+	{name: "config", args: "[get <key> | set <key> <value>]", summary: "Show or change the " + config.FileName + " settings", run: runConfig},
+	{name: "doctor", summary: "Check that everything autotest needs is set up", run: runDoctor},
+	{name: "stale", args: "[-- generate flags]", summary: "List generated tests whose source changed, and regenerate or augment them", run: runStale},
+	{name: "install-hook", summary: "Install a git pre-commit or pre-push hook checking that changed files have tests", run: runInstallHook},
+	{name: "cache", args: "[prune]", summary: "Show the size of the generation cache, or prune it", run: runCache},
+}
+
 func main() {
-	root := flag.String("root", ".", "Root directory of the project")
-	fw := flag.String("fw", "auto", "Framework: auto, jest, or vitest")
-	out := flag.String("out", "", "Optional output directory for tests (mirrors structure)")
-	dryRun := flag.Bool("dry-run", false, "Print plan and diffs without writing")
-	changedOnly := flag.Bool("changed-only", false, "Limit to git diff against origin/main")
-	maxWorkers := flag.Int("max-workers", runtime.NumCPU(), "Maximum concurrent workers")
-	minCoverage := flag.Float64("min-coverage", 0, "Minimum coverage threshold (0-100); fail if below")
-	allowDirty := flag.Bool("allow-dirty", false, "Allow running with dirty working tree")
-	provider := flag.String("provider", "auggie", "AI provider: auggie, cursor")
-
-	flag.Parse()
-
-	// Handle login command
-	if len(flag.Args()) > 0 {
-		cmd := flag.Args()[0]
-		if cmd == "login" {
-			if err := gen.LoginToAuggie(); err != nil {
-				log.Fatalf("Login failed: %v", err)
-			}
-			return
-		}
-		if cmd == "help" || cmd == "-h" || cmd == "--help" {
-			fmt.Println("autotest - Auto-generate Jest/Vitest tests for TypeScript files")
-			fmt.Println("\nUsage:")
-			fmt.Println("  autotest login                Login to Augment Code (one time setup)")
-			fmt.Println("  autotest -root <path> [flags] Generate tests for project")
-			fmt.Println("\nExamples:")
-			fmt.Println("  ./autotest login")
-			fmt.Println("  ./autotest -root ./my-project -allow-dirty")
-			fmt.Println("  ./autotest -root ./my-project -allow-dirty -dry-run")
-			fmt.Println("  ./autotest -root ./my-project -provider cursor -allow-dirty")
-			fmt.Println("\nFlags:")
-			flag.PrintDefaults()
+	args := os.Args[1:]
+
+	// Flags without a command keep the original flat invocation working
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		if len(args) == 1 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+			printUsage()
 			return
 		}
+		runCommand(lookupCommand("generate"), args)
+		return
 	}
 
-	// Check if -root was explicitly provided
-	rootProvided := false
-	flag.Visit(func(f *flag.Flag) {
-		if f.Name == "root" {
-			rootProvided = true
+	name := args[0]
+	if name == "help" {
+		if len(args) > 1 {
+			if cmd := lookupCommand(args[1]); cmd != nil {
+				runCommand(cmd, []string{"-h"})
+			}
 		}
-	})
-
-	if !rootProvided {
-		fmt.Println("❌ Error: -root flag is required")
-		fmt.Println("\nUsage:")
-		fmt.Println("  ./autotest -root <project-path> -allow-dirty")
-		fmt.Println("\nExample:")
-		fmt.Println("  ./autotest -root ./my-project -allow-dirty")
-		fmt.Println("\nFor more help:")
-		fmt.Println("  ./autotest help")
-		os.Exit(1)
+		printUsage()
+		return
 	}
 
-	// Validate flags
-	if *fw != "auto" && *fw != "jest" && *fw != "vitest" {
-		log.Fatalf("invalid framework: %s (must be auto, jest, or vitest)", *fw)
-	}
-	if *minCoverage < 0 || *minCoverage > 100 {
-		log.Fatalf("min-coverage must be between 0 and 100")
-	}
-	if *maxWorkers < 1 {
-		log.Fatalf("max-workers must be at least 1")
-	}
-	if *provider != "auggie" && *provider != "cursor" {
-		log.Fatalf("invalid provider: %s (must be auggie or cursor)", *provider)
+	cmd := lookupCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", name)
+		printUsage()
+		os.Exit(2)
 	}
+	runCommand(cmd, args[1:])
+}
 
-	// Check git status unless --allow-dirty
-	if !*allowDirty {
-		dirty, err := scan.IsWorkingTreeDirty(*root)
-		if err != nil {
-			log.Fatalf("failed to check git status: %v", err)
-		}
-		if dirty {
-			log.Fatalf("working tree is dirty; commit changes or use --allow-dirty")
+// lookupCommand returns the command called name, or nil.
+func lookupCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
 		}
 	}
+	return nil
+}
 
-	// Detect framework
-	framework := *fw
-	if framework == "auto" {
-		detected, err := exec.DetectFramework(*root)
-		if err != nil {
-			log.Fatalf("failed to detect framework: %v", err)
-		}
-		framework = detected
-		fmt.Printf("Detected framework: %s\n", framework)
+// runCommand runs cmd with a flag set of its own, whose -h prints the command's help.
+func runCommand(cmd *command, args []string) {
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "%s\n\nUsage:\n  %s\n\nFlags:\n", cmd.summary, strings.TrimSpace("autotest "+cmd.name+" [flags] "+cmd.args))
+		fs.PrintDefaults()
 	}
+	cmd.run(fs, args)
+}
 
-	// Setup AI provider
-	switch *provider {
-	case "auggie":
-		fmt.Println("🤖 Using Auggie CLI for AI-powered test generation...")
-		if err := gen.EnsureAuggieCLIInstalled(); err != nil {
-			log.Fatalf("failed to setup Auggie CLI: %v", err)
-		}
-	case "cursor":
-		fmt.Println("🤖 Using Cursor AI for test generation...")
-		if err := gen.EnsureCursorCLIInstalled(); err != nil {
-			log.Fatalf("failed to setup Cursor CLI: %v\nPlease install Cursor IDE from https://cursor.sh/", err)
-		}
+// printUsage prints the list of commands.
+func printUsage() {
+	fmt.Println("autotest - Auto-generate Jest, Vitest, Mocha, Jasmine, Bun and node:test tests for TypeScript files")
+	fmt.Println("\nUsage:")
+	fmt.Println("  autotest <command> [flags]")
+	fmt.Println("  autotest -root <path> [flags]   Same as autotest generate")
+	fmt.Println("\nCommands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-13s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Println("\nExamples:")
+	fmt.Println("  ./autotest login")
+	fmt.Println("  ./autotest generate -root ./my-project -allow-dirty")
+	fmt.Println("  ./autotest generate -root ./my-project -allow-dirty -dry-run")
+	fmt.Println("  ./autotest plan -root ./my-project -json")
+	fmt.Println("  ./autotest coverage -root ./my-project -min 80")
+	fmt.Println("\nRun 'autotest help <command>' or 'autotest <command> -h' for the flags of a command.")
+}
+
+// logFlags are the logging flags of commands that report progress.
+type logFlags struct {
+	quiet   *bool
+	verbose *bool
+	format  *string
+}
+
+// addLogFlags defines the logging flags on fs.
+func addLogFlags(fs *flag.FlagSet) *logFlags {
+	return &logFlags{
+		quiet:   fs.Bool("q", false, "Quiet: only log warnings and errors"),
+		verbose: fs.Bool("v", false, "Verbose: also log debug details such as provider output"),
+		format:  fs.String("log-format", "text", "Log format: text or json"),
+	}
+}
 
-	// Scan for files needing tests
-	candidates, err := scan.FindCandidates(*root, *changedOnly)
+// logger returns the logger selected by the logging flags, writing to w (usually stderr), or
+// exits if the flags are invalid.
+func (lf *logFlags) logger(w io.Writer) *slog.Logger {
+	if *lf.quiet && *lf.verbose {
+		log.Fatalf("-q and -v cannot be used together")
+	}
+	format, err := logging.ParseFormat(*lf.format)
 	if err != nil {
-		log.Fatalf("failed to scan files: %v", err)
+		log.Fatalf("%v", err)
 	}
 
-	if len(candidates) == 0 {
-		fmt.Println("No files need tests.")
-		return
+	level := slog.LevelInfo
+	switch {
+	case *lf.quiet:
+		level = slog.LevelWarn
+	case *lf.verbose:
+		level = slog.LevelDebug
 	}
+	return logging.New(w, level, format)
+}
 
-	fmt.Printf("Found %d file(s) needing tests\n", len(candidates))
+// args returns the command line flags selecting the same logging, for a command run on behalf
+// of another.
+func (lf *logFlags) args() []string {
+	args := []string{"-log-format", *lf.format}
+	if *lf.quiet {
+		args = append(args, "-q")
+	}
+	if *lf.verbose {
+		args = append(args, "-v")
+	}
+	return args
+}
 
-	// Build work queue
-	type workItem struct {
-		path string
-		code string
+// terminal returns a terminal keeping a progress line at the bottom of stderr, or nil when
+// progress should be logged instead: stderr isn't a terminal, logs are JSON, or -q was given.
+func (lf *logFlags) terminal() *progress.Terminal {
+	if *lf.quiet || *lf.format != string(logging.Text) || !output.IsTerminal(os.Stderr) {
+		return nil
 	}
-	workQueue := make([]workItem, 0, len(candidates))
+	return progress.NewTerminal(os.Stderr)
+}
+
+// fatalf logs a formatted error message and exits.
+func fatalf(logger *slog.Logger, format string, args ...interface{}) {
+	logger.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
 
-	for _, candidate := range candidates {
-		code, err := os.ReadFile(candidate)
-		if err != nil {
-			log.Printf("warning: failed to read %s: %v", candidate, err)
-			continue
+// errInterrupted is the cause of contexts canceled by Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// interruptContext returns a context canceled with errInterrupted on the first SIGINT or
+// SIGTERM, so running work can wind down and completed results are kept. A second signal
+// exits immediately. Call stop when the work is done.
+func interruptContext(logger *slog.Logger) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+
+	go func() {
+		select {
+		case <-signals:
+		case <-stopped:
+			return
 		}
-		workQueue = append(workQueue, workItem{path: candidate, code: string(code)})
-	}
+		logger.Warn("interrupted; stopping running work and keeping completed results (press Ctrl-C again to quit now)")
+		cancel(errInterrupted)
 
-	// Process with worker pool
-	results := make(chan gen.TestResult, len(workQueue))
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, *maxWorkers)
-
-	for _, item := range workQueue {
-		wg.Add(1)
-		go func(wi workItem) {
-			defer wg.Done()
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			testPath := scan.DefaultTestPath(wi.path, framework, *out)
-			relPath, _ := filepath.Rel(*root, wi.path)
-
-			// Generate test with selected AI provider
-			var testCode string
-			var err error
-
-			switch *provider {
-			case "auggie":
-				testCode, err = gen.GenerateTestWithAugmentCLI(relPath, wi.code, framework, "")
-			case "cursor":
-				testCode, err = gen.GenerateTestWithCursorCLI(relPath, wi.code, framework, "")
-			default:
-				err = fmt.Errorf("unsupported provider: %s", *provider)
-			}
+		select {
+		case <-signals:
+			os.Exit(130)
+		case <-stopped:
+		}
+	}()
 
-			if err != nil {
-				results <- gen.TestResult{
-					SourcePath: wi.path,
-					TestPath:   testPath,
-					Error:      fmt.Errorf("generation failed: %w", err),
-				}
-				return
-			}
+	return ctx, func() {
+		signal.Stop(signals)
+		close(stopped)
+		cancel(nil)
+	}
+}
 
-			results <- gen.TestResult{
-				SourcePath: wi.path,
-				TestPath:   testPath,
-				TestCode:   testCode,
-			}
-		}(item)
+// projectFlags are the flags of commands that select and place test files.
+type projectFlags struct {
+	root         *string
+	fwName       *string
+	out          *string
+	placement    *string
+	testTemplate *string
+	changedOnly  *bool
+	base         *string
+	staged       *bool
+	hunks        *bool
+	mode         *string
+}
+
+// addProjectFlags defines the project flags on fs.
+func addProjectFlags(fs *flag.FlagSet) *projectFlags {
+	return &projectFlags{
+		root:         fs.String("root", ".", "Root directory of the project"),
+		fwName:       fs.String("fw", "auto", "Framework: auto, "+strings.Join(framework.Names(), ", ")),
+		out:          fs.String("out", "", "Test root for mirror placement, relative to -root (implies -placement mirror)"),
+		placement:    fs.String("placement", "", "Test placement: colocated, __tests__, mirror, or template (default colocated, or mirror with -out)"),
+		testTemplate: fs.String("test-template", "", "Test path template for -placement template, e.g. {dir}/__tests__/{name}.{fw}.ts"),
+		changedOnly:  fs.Bool("changed-only", false, "Limit to files changed on the current branch since it left -base, including staged, unstaged and untracked changes"),
+		base:         fs.String("base", "", "Branch, tag or commit -changed-only compares against, from where the branches diverged (implies -changed-only; default origin/main, or origin/master)"),
+		staged:       fs.Bool("staged", false, "Limit to files staged for the next commit (implies -changed-only)"),
+		hunks:        fs.Bool("hunks", false, "With -changed-only, also target the exports the changed lines touch in files that have tests, and add their tests to the existing test files; without it only changed files without tests get whole test files"),
+		mode:         fs.String("mode", "generate", "Mode: generate (files without tests) or augment (also add tests for untested exports to existing test files)"),
 	}
+}
 
-	wg.Wait()
-	close(results)
-
-	// Collect results
-	var testResults []gen.TestResult
-	var failedCount int
-	for result := range results {
-		if result.Error != nil {
-			log.Printf("error: %s: %v", result.SourcePath, result.Error)
-			failedCount++
-		} else {
-			testResults = append(testResults, result)
-		}
+// forcedFramework returns the framework chosen with -fw, or a zero Framework for auto-detection.
+// It also validates -mode, which every project command needs.
+func (pf *projectFlags) forcedFramework() (framework.Framework, error) {
+	if *pf.mode != "generate" && *pf.mode != "augment" {
+		return framework.Framework{}, fmt.Errorf("invalid mode: %s (must be generate or augment)", *pf.mode)
 	}
+	return lookupFramework(*pf.fwName)
+}
 
-	if len(testResults) == 0 {
-		if failedCount > 0 {
-			log.Fatalf("all generations failed")
+// testPlacement builds the test placement from -placement, -out and -test-template.
+// Without -placement, -out implies mirror placement and colocated is the default.
+func (pf *projectFlags) testPlacement() (scan.Placement, error) {
+	strategy := *pf.placement
+	if strategy == "" {
+		strategy = scan.PlacementColocated
+		if *pf.out != "" {
+			strategy = scan.PlacementMirror
 		}
-		fmt.Println("No tests generated.")
-		return
 	}
+	return scan.NewPlacement(strategy, *pf.root, *pf.out, *pf.testTemplate)
+}
 
-	fmt.Printf("Generated %d test(s)\n", len(testResults))
-
-	// Dry-run: print plan
-	if *dryRun {
-		fmt.Println("\n=== DRY RUN: Test Generation Plan ===")
-		for _, result := range testResults {
-			fmt.Printf("\nSource: %s\n", result.SourcePath)
-			fmt.Printf("Test:   %s\n", result.TestPath)
-			fmt.Printf("Lines:  %d\n", len(result.TestCode))
-		}
-		fmt.Println("\n(No files written in dry-run mode)")
-		return
+// changeSet returns the changes selected by -changed-only, -base and -staged, or nil to scan
+// every file.
+func (pf *projectFlags) changeSet() (*scan.ChangeSet, error) {
+	if *pf.base != "" && *pf.staged {
+		return nil, errors.New("-base cannot be used with -staged, which compares against HEAD")
 	}
+	if !*pf.changedOnly && *pf.base == "" && !*pf.staged {
+		return nil, nil
+	}
+	return &scan.ChangeSet{Base: *pf.base, Staged: *pf.staged}, nil
+}
 
-	// Write tests
-	var written int
-	for _, result := range testResults {
-		dir := filepath.Dir(result.TestPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			log.Printf("error: failed to create directory %s: %v", dir, err)
-			continue
-		}
+// augment reports whether -mode augment was given.
+func (pf *projectFlags) augment() bool {
+	return *pf.mode == "augment"
+}
 
-		if err := os.WriteFile(result.TestPath, []byte(result.TestCode), 0644); err != nil {
-			log.Printf("error: failed to write %s: %v", result.TestPath, err)
-			continue
-		}
+// budgetFlags are the spending limits of a generation run.
+type budgetFlags struct {
+	maxCost   *float64
+	maxTokens *int
+}
 
-		fmt.Printf("✓ %s\n", result.TestPath)
-		written++
+// addBudgetFlags defines the budget flags on fs.
+func addBudgetFlags(fs *flag.FlagSet) *budgetFlags {
+	return &budgetFlags{
+		maxCost:   fs.Float64("max-cost", 0, "Stop starting new files once provider calls cost this many US dollars (0 for no limit)"),
+		maxTokens: fs.Int("max-tokens", 0, "Stop starting new files once provider calls used this many tokens (0 for no limit)"),
 	}
+}
 
-	fmt.Printf("\nWrote %d test file(s)\n", written)
+// budget returns an empty budget with the limits of the flags.
+func (bf *budgetFlags) budget() (*cost.Budget, error) {
+	if *bf.maxCost < 0 || *bf.maxTokens < 0 {
+		return nil, errors.New("max-cost and max-tokens must not be negative")
+	}
+	return &cost.Budget{MaxCost: *bf.maxCost, MaxTokens: *bf.maxTokens}, nil
+}
 
-	// Run tests on affected scope
-	if written > 0 {
-		testPaths := make([]string, len(testResults))
-		for i, result := range testResults {
-			testPaths[i] = result.TestPath
-		}
+// lookupFramework returns the framework named by -fw, or a zero Framework for "auto".
+func lookupFramework(name string) (framework.Framework, error) {
+	if name == "auto" {
+		return framework.Framework{}, nil
+	}
+	fw, err := framework.Lookup(name)
+	if err != nil {
+		return framework.Framework{}, fmt.Errorf("invalid framework: %w", err)
+	}
+	return fw, nil
+}
 
-		fmt.Println("\nRunning tests...")
-		if err := exec.RunTests(testPaths, framework, *root); err != nil {
-			log.Printf("warning: test run failed: %v", err)
-		}
+// projectPackages returns the packages whose tests run and coverage work on: the workspace
+// packages of a monorepo, or the project itself.
+func projectPackages(ws *scan.Workspace) []scan.Package {
+	if !ws.IsMonorepo() {
+		return ws.Packages
 	}
 
-	// Check coverage if requested
-	if *minCoverage > 0 {
-		fmt.Printf("\nChecking coverage (minimum: %.1f%%)\n", *minCoverage)
-		coverage, err := exec.GetCoverage(*root, framework)
-		if err != nil {
-			log.Printf("warning: failed to get coverage: %v", err)
-		} else if coverage < *minCoverage {
-			log.Fatalf("coverage %.1f%% is below minimum %.1f%%", coverage, *minCoverage)
+	var pkgs []scan.Package
+	for _, pkg := range ws.Packages {
+		if filepath.Clean(pkg.Dir) != filepath.Clean(ws.Root) {
+			pkgs = append(pkgs, pkg)
 		}
-		fmt.Printf("Coverage: %.1f%% ✓\n", coverage)
 	}
+	return pkgs
+}
 
-	fmt.Println("\nDone!")
+// packageManager returns the package manager configured in cfg, or the one detected for dir.
+func packageManager(cfg *config.Config, dir string, root string) (exec.PackageManager, error) {
+	if cfg.PackageManager != "" {
+		return exec.ParsePackageManager(cfg.PackageManager)
+	}
+	return exec.DetectPackageManager(dir, root), nil
 }
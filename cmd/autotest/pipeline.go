@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tanerincode/auto-test-generator/internal/config"
+	"github.com/tanerincode/auto-test-generator/internal/framework"
+	"github.com/tanerincode/auto-test-generator/internal/gen"
+	"github.com/tanerincode/auto-test-generator/internal/output"
+	"github.com/tanerincode/auto-test-generator/internal/report"
+	"github.com/tanerincode/auto-test-generator/internal/scan"
+	"github.com/tanerincode/auto-test-generator/internal/state"
+)
+
+// writeStage records generation results as workers finish them. Test files are written right
+// away, so a crash or Ctrl-C keeps what was done, and the tests of a package are queued as soon
+// as all of its files are recorded.
+type writeStage struct {
+	ctx      context.Context
+	logger   *slog.Logger
+	root     string
+	ws       *scan.Workspace
+	rep      *report.Report
+	writer   *output.Writer // nil in a dry run
+	state    *state.State   // nil in a dry run
+	validate bool
+
+	remaining map[string]int      // unrecorded work items per package directory
+	written   map[string][]string // written test files per package directory
+	tests     chan<- testBatch    // nil when no tests run
+
+	planned                     []gen.TestResult // results of a dry run, printed at the end
+	generated, failed, canceled int
+	writtenCount                int
+}
+
+// newWriteStage returns a stage expecting the results of workQueue. Without a writer, results
+// are kept for the dry-run output; written tests are recorded in st; without tests, no tests are
+// queued.
+func newWriteStage(ctx context.Context, logger *slog.Logger, root string, ws *scan.Workspace, rep *report.Report, writer *output.Writer, st *state.State, validate bool, workQueue []workItem, tests chan<- testBatch) *writeStage {
+	s := &writeStage{
+		ctx:       ctx,
+		logger:    logger,
+		root:      root,
+		ws:        ws,
+		rep:       rep,
+		writer:    writer,
+		state:     st,
+		validate:  validate,
+		remaining: make(map[string]int),
+		written:   make(map[string][]string),
+		tests:     tests,
+	}
+	for _, wi := range workQueue {
+		s.remaining[wi.pkg.Dir]++
+	}
+	return s
+}
+
+// record validates, writes and reports one result.
+func (s *writeStage) record(result gen.TestResult) {
+	pkgDir := s.ws.PackageFor(result.SourcePath).Dir
+	defer s.packageDone(pkgDir)
+
+	switch {
+	case result.Error != nil && report.CategoryOf(result.Error) == report.CategoryCanceled:
+		s.canceled++
+		s.rep.Add(reportEntry(s.root, s.ws, result, report.OutcomeCanceled))
+		return
+	case result.Error != nil:
+		s.fail(result, "generation failed")
+		return
+	}
+
+	if s.validate {
+		if err := gen.ValidateTest(result.TestCode); err != nil {
+			result.Error = &report.Error{Category: report.CategoryInvalid, Err: err}
+			s.fail(result, "generated tests are invalid")
+			return
+		}
+	}
+	s.generated++
+
+	if s.writer == nil {
+		s.planned = append(s.planned, result)
+		s.rep.Add(reportEntry(s.root, s.ws, result, report.OutcomePlanned))
+		return
+	}
+
+	outcome, err := s.writer.Write(result.TestPath, result.TestCode, len(result.Augmented) > 0)
+	if err != nil {
+		result.Error = &report.Error{Category: report.CategoryWrite, Err: err}
+		s.fail(result, "write failed")
+		return
+	}
+	s.rep.Add(reportEntry(s.root, s.ws, result, outcome.String()))
+	if outcome.Written() || outcome == output.Unchanged {
+		s.remember(result)
+	}
+
+	if outcome == output.Created || outcome == output.Updated {
+		s.logger.Info("wrote test file", "file", result.TestPath, "outcome", outcome.String())
+		s.written[pkgDir] = append(s.written[pkgDir], result.TestPath)
+		s.writtenCount++
+	} else {
+		s.logger.Info("kept existing test file", "file", result.TestPath, "outcome", outcome.String())
+	}
+}
+
+// remember records the test file of result in the state, so stale can find it once its source
+// changes.
+func (s *writeStage) remember(result gen.TestResult) {
+	if s.state == nil {
+		return
+	}
+	source, _ := filepath.Rel(s.root, result.SourcePath)
+	test, _ := filepath.Rel(s.root, result.TestPath)
+	s.state.Add(state.Record{
+		Source:     source,
+		SourceHash: result.SourceHash,
+		Test:       test,
+		Generator:  result.Provider,
+		Generated:  time.Now().UTC(),
+	})
+}
+
+// fail logs and reports a failed result.
+func (s *writeStage) fail(result gen.TestResult, msg string) {
+	s.logger.Error(msg, "file", result.SourcePath, "error", result.Error)
+	s.failed++
+	s.rep.Add(reportEntry(s.root, s.ws, result, report.OutcomeFailed))
+}
+
+// packageDone counts a recorded result of the package at dir, and queues the package's tests
+// once its last result is recorded. Nothing is queued after ctx is done.
+func (s *writeStage) packageDone(dir string) {
+	s.remaining[dir]--
+	if s.remaining[dir] > 0 || s.tests == nil || len(s.written[dir]) == 0 || s.ctx.Err() != nil {
+		return
+	}
+	s.tests <- testBatch{dir: dir, paths: s.written[dir]}
+}
+
+// testBatch is the test files written for one package.
+type testBatch struct {
+	dir   string
+	paths []string
+}
+
+// runTestStage runs the tests of every batch received on batches, up to workers packages at a
+// time, while generation goes on. With take, each run takes the terminal while it prints. The
+// returned channel yields the outcome of every package that was run, keyed by package directory,
+// once batches is closed.
+func runTestStage(ctx context.Context, logger *slog.Logger, cfg *config.Config, ws *scan.Workspace, frameworks map[string]framework.Framework, batches <-chan testBatch, workers int, take func() func()) <-chan map[string]error {
+	done := make(chan map[string]error, 1)
+	outcomes := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				var release func()
+				if take != nil {
+					release = take()
+				}
+				for dir, err := range runTests(ctx, logger, cfg, ws, frameworks, map[string][]string{batch.dir: batch.paths}) {
+					mu.Lock()
+					outcomes[dir] = err
+					mu.Unlock()
+				}
+				if release != nil {
+					release()
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		done <- outcomes
+	}()
+	return done
+}
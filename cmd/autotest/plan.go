@@ -0,0 +1,446 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tanerincode/auto-test-generator/internal/config"
+	"github.com/tanerincode/auto-test-generator/internal/cost"
+	"github.com/tanerincode/auto-test-generator/internal/diff"
+	"github.com/tanerincode/auto-test-generator/internal/exec"
+	"github.com/tanerincode/auto-test-generator/internal/framework"
+	"github.com/tanerincode/auto-test-generator/internal/gen"
+	"github.com/tanerincode/auto-test-generator/internal/limit"
+	"github.com/tanerincode/auto-test-generator/internal/scan"
+)
+
+// workItem is a source file scheduled for test generation.
+type workItem struct {
+	path      string
+	relPath   string
+	relTest   string // testPath relative to the root, like relPath
+	code      string
+	testPath  string
+	pkg       scan.Package
+	framework framework.Framework
+	focus     *gen.Focus
+}
+
+// planEntry describes one file of a plan.
+type planEntry struct {
+	Source          string   `json:"source"`
+	Test            string   `json:"test"`
+	Package         string   `json:"package"`
+	Framework       string   `json:"framework"`
+	Exports         []string `json:"exports"`
+	Augment         []string `json:"augment,omitempty"`
+	Changed         []string `json:"changed,omitempty"`
+	EstimatedTokens int      `json:"estimatedPromptTokens"`
+	EstimatedCost   *float64 `json:"estimatedPromptCost,omitempty"`
+}
+
+// plan is what a generation run would do, as printed by the plan subcommand.
+type plan struct {
+	Files           []planEntry `json:"files"`
+	EstimatedTokens int         `json:"estimatedPromptTokens"`
+	// Model is the model of the first provider that uses tokens, which prices the estimates.
+	Model         string     `json:"model,omitempty"`
+	EstimatedCost *float64   `json:"estimatedPromptCost,omitempty"`
+	Budget        planBudget `json:"budget"`
+	// Undetected are the packages whose framework can't be detected, so their candidates were
+	// not checked.
+	Undetected []undetectedPackage `json:"undetected,omitempty"`
+}
+
+// undetectedPackage is a package of a plan whose framework can't be detected.
+type undetectedPackage struct {
+	Package string   `json:"package"`
+	Files   []string `json:"files"`
+}
+
+// planBudget is the budget of a plan and how far it goes.
+type planBudget struct {
+	MaxCost   float64 `json:"maxCost,omitempty"`
+	MaxTokens int     `json:"maxTokens,omitempty"`
+	// Files is how many files start before the prompt estimates alone reach a limit: an upper
+	// bound, as completion tokens add to the spending.
+	Files int `json:"files"`
+}
+
+// runPlan implements the plan subcommand. It resolves candidates, test paths, frameworks and
+// exports like a generation run would, but never calls a provider.
+func runPlan(fs *flag.FlagSet, args []string) {
+	pf := addProjectFlags(fs)
+	jsonOut := fs.Bool("json", false, "Print the plan as JSON")
+	onMissing := fs.String("on-missing", "", "When files need tests: warn, or fail with exit status 1, e.g. in a git hook or CI; default neither")
+	provider := fs.String("provider", "", "AI provider, or comma-separated fallback chain, whose prices the estimates use; default the configured providers, or auggie")
+	bf := addBudgetFlags(fs)
+	lf := addLogFlags(fs)
+	fs.Parse(args)
+	logger := lf.logger(os.Stderr)
+
+	forcedFramework, err := pf.forcedFramework()
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+	if *onMissing != "" && *onMissing != "warn" && *onMissing != "fail" {
+		fatalf(logger, "invalid on-missing: %s (must be warn or fail)", *onMissing)
+	}
+	testPlacement, err := pf.testPlacement()
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+	changes, err := pf.changeSet()
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+	root := *pf.root
+	budget, err := bf.budget()
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+
+	cfg, err := config.Load(root)
+	if err != nil {
+		fatalf(logger, "failed to load config: %v", err)
+	}
+	providers, err := resolveProviders(*provider, cfg)
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+	if err := checkPriced(providers, cfg, budget); err != nil {
+		fatalf(logger, "%v", err)
+	}
+	prices := cost.DefaultPrices.With(cfg.Prices)
+
+	ws, err := scan.LoadWorkspace(root)
+	if err != nil {
+		fatalf(logger, "failed to load workspace: %v", err)
+	}
+
+	candidates, lines, err := findCandidates(root, ws, changes, *pf.hunks, testPlacement, pf.augment())
+	if err != nil {
+		fatalf(logger, "failed to scan files: %v", err)
+	}
+
+	frameworks := detectFrameworks(logger, ws, candidatePackages(ws, candidates), forcedFramework)
+	workQueue := buildWorkQueue(logger, root, ws, candidates, frameworks, testPlacement, pf.augment(), lines, nil, limit.New(runtime.NumCPU(), 0))
+
+	p := plan{Files: []planEntry{}, Budget: planBudget{MaxCost: budget.MaxCost, MaxTokens: budget.MaxTokens}}
+	for _, name := range providers {
+		if p.Model = providerModel(name, openAIConfig(cfg)); p.Model != "" {
+			break
+		}
+	}
+	_, priced := prices.Cost(p.Model, 0, 0)
+	if priced {
+		p.EstimatedCost = new(float64)
+	}
+
+	groups := ws.Group(candidates)
+	for _, pkg := range candidatePackages(ws, candidates) {
+		if _, ok := frameworks[pkg.Dir]; ok {
+			continue
+		}
+		undetected := undetectedPackage{Package: pkg.Name}
+		for _, candidate := range groups[pkg.Dir] {
+			rel, _ := filepath.Rel(root, candidate)
+			undetected.Files = append(undetected.Files, rel)
+		}
+		p.Undetected = append(p.Undetected, undetected)
+	}
+
+	for _, wi := range workQueue {
+		testRel, _ := filepath.Rel(root, wi.testPath)
+		entry := planEntry{
+			Source:          wi.relPath,
+			Test:            testRel,
+			Package:         wi.pkg.Name,
+			Framework:       wi.framework.Name,
+			Exports:         gen.Exports(wi.code),
+			EstimatedTokens: gen.EstimatePromptTokens(wi.relPath, wi.relTest, wi.code, wi.framework, "", wi.focus),
+		}
+		if wi.focus != nil {
+			entry.Augment = wi.focus.Exports
+			entry.Changed = wi.focus.Changed
+		}
+		promptCost, _ := prices.Cost(p.Model, entry.EstimatedTokens, 0)
+		if priced {
+			entry.EstimatedCost = &promptCost
+			*p.EstimatedCost += promptCost
+		}
+
+		// A file starts while the spending so far is below every limit
+		if budget.Err() == nil {
+			p.Budget.Files++
+		}
+		budget.Add(entry.EstimatedTokens, promptCost)
+
+		p.Files = append(p.Files, entry)
+		p.EstimatedTokens += entry.EstimatedTokens
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(p); err != nil {
+			fatalf(logger, "failed to encode plan: %v", err)
+		}
+	} else {
+		printPlan(p)
+	}
+
+	unchecked := 0
+	for _, pkg := range p.Undetected {
+		unchecked += len(pkg.Files)
+	}
+	switch {
+	case *onMissing == "warn" && len(p.Files) > 0:
+		logger.Warn("files need tests", "count", len(p.Files), "fix", "run autotest generate with the same flags")
+	case *onMissing == "fail" && len(p.Files) > 0:
+		fatalf(logger, "%d file(s) need tests; run autotest generate with the same flags", len(p.Files))
+	}
+	switch {
+	case *onMissing == "warn" && unchecked > 0:
+		logger.Warn("files not checked: no framework detected for their package", "count", unchecked, "fix", "use -fw to choose one")
+	case *onMissing == "fail" && unchecked > 0:
+		fatalf(logger, "%d file(s) not checked: no framework detected for their package; use -fw to choose one", unchecked)
+	}
+}
+
+// printPlan prints a plan for humans.
+func printPlan(p plan) {
+	for _, pkg := range p.Undetected {
+		fmt.Printf("Not checked, no framework detected for package %s: %s\n", pkg.Package, strings.Join(pkg.Files, ", "))
+	}
+	if len(p.Files) == 0 && len(p.Undetected) > 0 {
+		fmt.Println("No checked files need tests.")
+		return
+	}
+	if len(p.Files) == 0 {
+		fmt.Println("No files need tests.")
+		return
+	}
+
+	fmt.Println("\n=== Test Generation Plan ===")
+	for _, entry := range p.Files {
+		fmt.Printf("\nSource:    %s\n", entry.Source)
+		fmt.Printf("Test:      %s\n", entry.Test)
+		fmt.Printf("Package:   %s\n", entry.Package)
+		fmt.Printf("Framework: %s\n", entry.Framework)
+		fmt.Printf("Exports:   %s\n", strings.Join(entry.Exports, ", "))
+		if len(entry.Augment) > 0 {
+			fmt.Printf("Adds:      %s\n", strings.Join(entry.Augment, ", "))
+		}
+		if len(entry.Changed) > 0 {
+			fmt.Printf("Changed:   %s\n", strings.Join(entry.Changed, ", "))
+		}
+		fmt.Printf("Tokens:    ~%d\n", entry.EstimatedTokens)
+		if entry.EstimatedCost != nil {
+			fmt.Printf("Cost:      ~%s\n", cost.Format(*entry.EstimatedCost))
+		}
+	}
+
+	fmt.Printf("\n%d file(s), ~%d prompt token(s)", len(p.Files), p.EstimatedTokens)
+	switch {
+	case p.EstimatedCost != nil:
+		fmt.Printf(", ~%s with %s", cost.Format(*p.EstimatedCost), p.Model)
+	case p.Model != "":
+		fmt.Printf(" (no price for %s)", p.Model)
+	}
+	fmt.Println("; no provider was called")
+	fmt.Println("Estimates cover prompts only; completion tokens come on top.")
+
+	var limits []string
+	if p.Budget.MaxCost > 0 {
+		limits = append(limits, "max cost "+cost.Format(p.Budget.MaxCost))
+	}
+	if p.Budget.MaxTokens > 0 {
+		limits = append(limits, fmt.Sprintf("max tokens %d", p.Budget.MaxTokens))
+	}
+	if len(limits) == 0 {
+		fmt.Println("Budget:    none (set -max-cost or -max-tokens)")
+		return
+	}
+	fmt.Printf("Budget:    %s; at most %d of %d file(s) start before it runs out\n", strings.Join(limits, ", "), p.Budget.Files, len(p.Files))
+}
+
+// candidatePackages returns the packages owning at least one of candidates.
+func candidatePackages(ws *scan.Workspace, candidates []string) []scan.Package {
+	groups := ws.Group(candidates)
+	var pkgs []scan.Package
+	for _, pkg := range ws.Packages {
+		if len(groups[pkg.Dir]) > 0 {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
+// detectFrameworks detects the framework of each of pkgs, keyed by package directory, falling back
+// to the workspace root for hoisted dev dependencies. Packages whose framework can't be detected
+// are left out.
+func detectFrameworks(logger *slog.Logger, ws *scan.Workspace, pkgs []scan.Package, forced framework.Framework) map[string]framework.Framework {
+	frameworks := make(map[string]framework.Framework)
+	for _, pkg := range pkgs {
+		fw := forced
+		if fw.Name == "" {
+			detection, err := exec.DetectFramework(pkg.Dir)
+			if errors.Is(err, exec.ErrNoFramework) && ws.IsMonorepo() {
+				detection, err = exec.DetectFramework(ws.Root)
+			}
+			if err != nil {
+				logger.Warn("skipping package: failed to detect framework", "package", pkg.Name, "error", err)
+				continue
+			}
+			fw = detection.Framework
+			logger.Info("detected framework", "package", pkg.Name, "framework", fw.Name, "confidence", detection.Confidence.String(), "source", detection.Reason.Source)
+		}
+		frameworks[pkg.Dir] = fw
+	}
+	return frameworks
+}
+
+// findCandidates returns the source files to consider, all of them or the changed ones. When
+// targeting the hunks of changes, it also returns their changed lines, and files that have tests
+// are kept for them.
+func findCandidates(root string, ws *scan.Workspace, changes *scan.ChangeSet, hunks bool, placement scan.Placement, augment bool) ([]string, map[string][]diff.Range, error) {
+	if changes == nil || !hunks {
+		candidates, err := scan.FindCandidates(root, changes, ws, placement, augment)
+		return candidates, nil, err
+	}
+
+	candidates, err := scan.FindCandidates(root, changes, ws, placement, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	lines, err := scan.ChangedLines(root, *changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return candidates, lines, nil
+}
+
+// buildWorkQueue reads the candidates of packages with a framework and resolves their test paths,
+// analyzing as many candidates at once as local allows. In augment mode, files that already have
+// tests are only kept for their untested exports; with lines, also for the exports whose lines
+// changed.
+func buildWorkQueue(logger *slog.Logger, root string, ws *scan.Workspace, candidates []string, frameworks map[string]framework.Framework, placement scan.Placement, augment bool, lines map[string][]diff.Range, tests map[string]string, local *limit.Limiter) []workItem {
+	items := make([]*workItem, len(candidates))
+	var wg sync.WaitGroup
+	for i, candidate := range candidates {
+		pkg := ws.PackageFor(candidate)
+		fw, ok := frameworks[pkg.Dir]
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, candidate string) {
+			defer wg.Done()
+			local.Acquire(context.Background())
+			defer local.Release()
+			items[i] = workItemFor(logger, root, candidate, pkg, fw, placement, augment, lines, tests[candidate])
+		}(i, candidate)
+	}
+	wg.Wait()
+
+	// Keep the candidates' order, whichever finished first
+	workQueue := make([]workItem, 0, len(candidates))
+	for _, item := range items {
+		if item != nil {
+			workQueue = append(workQueue, *item)
+		}
+	}
+	return workQueue
+}
+
+// focusFor returns the focus of a file whose tests are existing: its untested exports in augment
+// mode, and when targeted, the exports touched by the changed lines in ranges. A file that is new
+// since the base has nil ranges, and only its untested exports count as changed. It returns nil
+// if there is nothing to generate.
+func focusFor(code string, existing string, augment bool, targeted bool, ranges []diff.Range) *gen.Focus {
+	untested := gen.UntestedExports(code, existing)
+	var touched []string
+	if targeted {
+		touched = untested
+		if ranges != nil {
+			touched = gen.ChangedExports(code, ranges)
+		}
+	}
+
+	focus := &gen.Focus{Existing: existing, Lines: ranges}
+	for _, name := range gen.Exports(code) {
+		isUntested, isTouched := slices.Contains(untested, name), slices.Contains(touched, name)
+		switch {
+		case isTouched && !isUntested:
+			focus.Exports = append(focus.Exports, name)
+			focus.Changed = append(focus.Changed, name)
+		case isTouched || (augment && isUntested):
+			focus.Exports = append(focus.Exports, name)
+		}
+	}
+	if len(focus.Exports) == 0 {
+		return nil
+	}
+	return focus
+}
+
+// workItemFor reads candidate and resolves its test path, or returns nil if it needs no tests or
+// can't be read.
+func workItemFor(logger *slog.Logger, root string, candidate string, pkg scan.Package, fw framework.Framework, placement scan.Placement, augment bool, lines map[string][]diff.Range, recorded string) *workItem {
+	code, err := os.ReadFile(candidate)
+	if err != nil {
+		logger.Warn("failed to read source file", "file", candidate, "error", err)
+		return nil
+	}
+
+	testPath := placement.ForPackage(pkg).TestPath(candidate, fw)
+	existingPath, hasTest := placement.ForPackage(pkg).ExistingTest(candidate)
+	if recorded != "" {
+		_, err := os.Stat(recorded)
+		testPath, existingPath, hasTest = recorded, recorded, err == nil
+	}
+
+	var focus *gen.Focus
+	ranges, targeted := lines[candidate]
+	if augment || targeted {
+		if hasTest {
+			existing, err := os.ReadFile(existingPath)
+			if err != nil {
+				logger.Warn("failed to read test file", "file", existingPath, "error", err)
+				return nil
+			}
+			focus = focusFor(string(code), string(existing), augment, targeted, ranges)
+			if focus == nil {
+				logger.Debug("no exports to add tests for", "file", candidate)
+				return nil
+			}
+			focus.TestPath = existingPath
+			testPath = existingPath
+		}
+	}
+
+	relPath, _ := filepath.Rel(root, candidate)
+	relTest, _ := filepath.Rel(root, testPath)
+	return &workItem{
+		path:      candidate,
+		relPath:   relPath,
+		relTest:   relTest,
+		code:      string(code),
+		testPath:  testPath,
+		pkg:       pkg,
+		framework: fw,
+		focus:     focus,
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tanerincode/auto-test-generator/internal/cache"
+	"github.com/tanerincode/auto-test-generator/internal/config"
+	"github.com/tanerincode/auto-test-generator/internal/cost"
+	"github.com/tanerincode/auto-test-generator/internal/exec"
+	"github.com/tanerincode/auto-test-generator/internal/gen"
+	"github.com/tanerincode/auto-test-generator/internal/limit"
+)
+
+// providerNames are the providers -provider accepts, alone or as a comma-separated chain.
+var providerNames = []string{"auggie", "cursor", "openai", "offline"}
+
+// parseProviders splits a comma-separated fallback chain such as "auggie,openai,offline" and
+// validates its provider names.
+func parseProviders(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		valid := false
+		for _, known := range providerNames {
+			valid = valid || name == known
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid provider: %s (must be one of %s, or a comma-separated list of them)", name, strings.Join(providerNames, ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// resolveProviders returns the provider chain of the -provider value list, which defaults to the
+// configured providers, then to auggie.
+func resolveProviders(list string, cfg *config.Config) ([]string, error) {
+	if list == "" {
+		list = cfg.Providers
+	}
+	if list == "" {
+		list = "auggie"
+	}
+	return parseProviders(list)
+}
+
+// openAIConfig returns the settings of the openai provider: the endpoint and model from cfg,
+// or the defaults, and the API key from the environment.
+func openAIConfig(cfg *config.Config) gen.OpenAIConfig {
+	openai := gen.OpenAIConfig{BaseURL: cfg.OpenAIBaseURL, Model: cfg.OpenAIModel, APIKey: os.Getenv(gen.OpenAIKeyEnv)}
+	if openai.BaseURL == "" {
+		openai.BaseURL = gen.DefaultOpenAIBaseURL
+	}
+	if openai.Model == "" {
+		openai.Model = gen.DefaultOpenAIModel
+	}
+	return openai
+}
+
+// providerLimiter returns the limiter of the remote provider name, with its concurrency and
+// requests per minute from cfg, or nil for providers that run locally.
+func providerLimiter(name string, cfg *config.Config) *limit.Limiter {
+	var concurrency, rpm int
+	switch name {
+	case "auggie":
+		concurrency, rpm = cfg.AuggieConcurrency, cfg.AuggieRPM
+		if concurrency == 0 {
+			concurrency = gen.DefaultAuggieConcurrency
+		}
+	case "openai":
+		concurrency, rpm = cfg.OpenAIConcurrency, cfg.OpenAIRPM
+		if concurrency == 0 {
+			concurrency = gen.DefaultOpenAIConcurrency
+		}
+	default:
+		return nil
+	}
+	return limit.New(concurrency, rpm)
+}
+
+// providerChain generates tests with the first of its providers that succeeds. Transient
+// failures are retried with backoff before falling back to the next provider.
+type providerChain struct {
+	names   []string
+	openai  gen.OpenAIConfig
+	retries int
+	backoff gen.Backoff
+	timeout time.Duration // of all provider calls of a file, with retries and fallbacks
+
+	limits map[string]*limit.Limiter // of the remote providers
+	local  *limit.Limiter            // shared with other local work, for the local providers
+
+	prices cost.Prices
+	budget *cost.Budget // charged with every call
+
+	cache     *cache.Cache // stores the tests of the providers that use tokens
+	readCache bool         // reuse cached tests; false with -no-cache
+}
+
+// setupProviders prepares every provider of names and returns the chain of those that can be
+// used. A provider that can't be set up is dropped with a warning while others remain.
+func setupProviders(logger *slog.Logger, names []string, cfg *config.Config, pm exec.PackageManager) (*providerChain, error) {
+	chain := &providerChain{
+		openai:  openAIConfig(cfg),
+		backoff: gen.DefaultBackoff,
+		limits:  make(map[string]*limit.Limiter),
+		prices:  cost.DefaultPrices.With(cfg.Prices),
+		budget:  &cost.Budget{},
+	}
+
+	var lastErr error
+	for _, name := range names {
+		var err error
+		switch name {
+		case "auggie":
+			if err = gen.EnsureAuggieCLIInstalled(logger, pm); err != nil {
+				err = fmt.Errorf("failed to setup Auggie CLI: %w", err)
+			}
+		case "cursor":
+			if err = gen.EnsureCursorCLIInstalled(logger); err != nil {
+				err = fmt.Errorf("failed to setup Cursor CLI: %w\nPlease install Cursor IDE from https://cursor.sh/", err)
+			}
+		case "openai":
+			if chain.openai.APIKey == "" {
+				err = fmt.Errorf("openai provider needs an API key in %s", gen.OpenAIKeyEnv)
+			}
+		}
+
+		if err != nil {
+			if len(names) > 1 {
+				logger.Warn("skipping provider", "provider", name, "error", err)
+			}
+			lastErr = err
+			continue
+		}
+		chain.names = append(chain.names, name)
+		if l := providerLimiter(name, cfg); l != nil {
+			chain.limits[name] = l
+		}
+		if model := chain.model(name); model != "" {
+			if _, ok := chain.prices[model]; !ok {
+				logger.Warn("no price for model; its calls count as free", "provider", name, "model", model, "fix", "add it to prices in "+config.FileName)
+			}
+		}
+	}
+
+	if len(chain.names) == 0 {
+		return nil, lastErr
+	}
+	logger.Info("using providers", "chain", strings.Join(chain.names, ","))
+	return chain, nil
+}
+
+// checkPriced returns an error if budget has a cost limit but a provider of names uses a model
+// without a price, whose calls the limit could not count.
+func checkPriced(names []string, cfg *config.Config, budget *cost.Budget) error {
+	if budget.MaxCost <= 0 {
+		return nil
+	}
+	prices := cost.DefaultPrices.With(cfg.Prices)
+	openai := openAIConfig(cfg)
+	for _, name := range names {
+		model := providerModel(name, openai)
+		if _, ok := prices[model]; model != "" && !ok {
+			return fmt.Errorf("-max-cost can't count the calls of provider %s: model %s has no price; add it to prices in %s, or use -max-tokens", name, model, config.FileName)
+		}
+	}
+	return nil
+}
+
+// providerModel returns the model the provider name is priced by, or "" for the local providers,
+// which use no tokens.
+func providerModel(name string, openai gen.OpenAIConfig) string {
+	switch name {
+	case "auggie":
+		return "auggie"
+	case "openai":
+		return openai.Model
+	}
+	return ""
+}
+
+// model returns the model the provider name is priced by.
+func (c *providerChain) model(name string) string {
+	return providerModel(name, c.openai)
+}
+
+// limiter returns the limiter of the provider name.
+func (c *providerChain) limiter(name string) *limit.Limiter {
+	if l, ok := c.limits[name]; ok {
+		return l
+	}
+	return c.local
+}
+
+// slots returns how many files may be in a provider call at once: the concurrency of the
+// provider that allows the most.
+func (c *providerChain) slots() int {
+	n := 1
+	for _, name := range c.names {
+		n = max(n, c.limiter(name).Concurrency())
+	}
+	return n
+}
+
+// generate returns the test code of wi from the first provider that succeeds, and records the
+// provider, the number of calls, their token usage and its cost in result, charging the budget.
+// The error of a failed chain lists the last error of every provider. The chain gives up on the
+// file once its timeout has passed.
+func (c *providerChain) generate(ctx context.Context, logger *slog.Logger, wi workItem, result *gen.TestResult) (string, error) {
+	if testCode, ok := c.cached(logger, wi, result); ok {
+		return testCode, nil
+	}
+
+	fileCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	stopped := func(err error) error {
+		if ctx.Err() == nil {
+			return fmt.Errorf("timed out after %s", c.timeout)
+		}
+		return err
+	}
+
+	var failures []string
+	for i, name := range c.names {
+		for attempt := 1; ; attempt++ {
+			result.Provider = name
+			result.Attempts++
+
+			testCode, usage, err := c.call(fileCtx, logger, name, wi)
+			spent, _ := c.prices.Cost(c.model(name), usage.PromptTokens, usage.CompletionTokens)
+			result.Usage = result.Usage.Add(usage)
+			result.Cost += spent
+			c.budget.Add(usage.Total(), spent)
+
+			if err == nil {
+				c.store(logger, name, wi, testCode)
+				return testCode, nil
+			}
+			if fileCtx.Err() != nil {
+				return "", stopped(err)
+			}
+
+			if !gen.Retryable(err) || attempt > c.retries {
+				failures = append(failures, name+": "+err.Error())
+				if i+1 < len(c.names) {
+					logger.Warn("provider failed, falling back", "file", wi.relPath, "provider", name, "next", c.names[i+1], "error", err)
+				}
+				break
+			}
+
+			// A provider asking to wait is asked nothing by any worker until then
+			if wait := gen.RetryAfter(err); wait > 0 {
+				c.limiter(name).Pause(wait)
+			}
+			delay := max(c.backoff.Delay(attempt), gen.RetryAfter(err))
+			logger.Warn("provider call failed, retrying", "file", wi.relPath, "provider", name, "attempt", attempt, "retryIn", delay.Round(time.Millisecond), "error", err)
+			if err := sleep(fileCtx, delay); err != nil {
+				return "", stopped(err)
+			}
+		}
+	}
+
+	return "", errors.New(strings.Join(failures, "; "))
+}
+
+// cacheKey returns the cache key of the tests of wi generated by the provider name.
+func (c *providerChain) cacheKey(name string, wi workItem) cache.Key {
+	resolved := wi.relPath + "\n" + wi.relTest
+	if wi.focus != nil {
+		resolved += "\n" + strings.Join(wi.focus.Exports, ",") + "\n" + wi.focus.Existing
+		if len(wi.focus.Changed) > 0 {
+			resolved += "\n" + strings.Join(wi.focus.Changed, ",") + "\n" + fmt.Sprint(wi.focus.Lines)
+		}
+	}
+	return cache.Key{
+		Source:        wi.code,
+		Context:       resolved,
+		PromptVersion: gen.PromptVersion,
+		Provider:      name,
+		Model:         c.model(name),
+		Framework:     wi.framework.Name,
+	}
+}
+
+// cached returns the cached tests of wi from the first provider of the chain that has them, and
+// records the provider in result.
+func (c *providerChain) cached(logger *slog.Logger, wi workItem, result *gen.TestResult) (string, bool) {
+	if c.cache == nil || !c.readCache {
+		return "", false
+	}
+	for _, name := range c.names {
+		if c.model(name) == "" {
+			continue
+		}
+		if entry, ok := c.cache.Get(c.cacheKey(name, wi)); ok {
+			logger.Info("using cached tests", "file", wi.relPath, "provider", name, "generated", entry.Created.Format(time.RFC3339))
+			result.Provider = name
+			result.Cached = true
+			return entry.Code, true
+		}
+	}
+	return "", false
+}
+
+// store caches the tests of wi generated by the provider name. Only tests that cost tokens and
+// look complete are cached, so a truncated answer is not reused.
+func (c *providerChain) store(logger *slog.Logger, name string, wi workItem, testCode string) {
+	if c.cache == nil || c.model(name) == "" || gen.ValidateTest(testCode) != nil {
+		return
+	}
+	entry := cache.Entry{Path: wi.relPath, Provider: name, Model: c.model(name), Created: time.Now(), Code: testCode}
+	if err := c.cache.Put(c.cacheKey(name, wi), entry); err != nil {
+		logger.Warn("failed to cache tests", "file", wi.relPath, "error", err)
+	}
+}
+
+// call makes a single call to the provider name once its limiter allows.
+func (c *providerChain) call(ctx context.Context, logger *slog.Logger, name string, wi workItem) (string, gen.Usage, error) {
+	l := c.limiter(name)
+	if err := l.Acquire(ctx); err != nil {
+		return "", gen.Usage{}, err
+	}
+	defer l.Release()
+
+	var testCode string
+	var usage gen.Usage
+	var err error
+	switch name {
+	case "auggie":
+		testCode, usage, err = gen.GenerateTestWithAugmentCLI(ctx, logger, wi.relPath, wi.relTest, wi.code, wi.framework, "", wi.focus)
+	case "cursor":
+		testCode, err = gen.GenerateTestWithCursorCLI(ctx, logger, wi.relPath, wi.relTest, wi.code, wi.framework, "", wi.focus)
+	case "openai":
+		testCode, usage, err = gen.GenerateTestWithOpenAI(ctx, logger, c.openai, wi.relPath, wi.relTest, wi.code, wi.framework, "", wi.focus)
+	case "offline":
+		logger.Info("generating tests", "file", wi.relPath, "provider", name)
+		testCode, err = gen.GenerateOfflineTest(wi.relPath, wi.relTest, wi.code, wi.framework, wi.focus)
+	default:
+		err = fmt.Errorf("unsupported provider: %s", name)
+	}
+
+	return testCode, usage, err
+}
+
+// sleep waits for d, or returns why ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tanerincode/auto-test-generator/internal/config"
+	"github.com/tanerincode/auto-test-generator/internal/cost"
+)
+
+func TestCheckPriced(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		cfg     config.Config
+		maxCost float64
+		wantErr bool
+	}{
+		{"no cost limit", []string{"auggie"}, config.Config{}, 0, false},
+		{"unpriced auggie", []string{"openai", "auggie"}, config.Config{}, 1, true},
+		{"priced auggie", []string{"auggie"}, config.Config{Prices: cost.Prices{"auggie": {Prompt: 1}}}, 1, false},
+		{"default openai model", []string{"openai"}, config.Config{}, 1, false},
+		{"unpriced openai model", []string{"openai"}, config.Config{OpenAIModel: "custom"}, 1, true},
+		{"local providers", []string{"cursor", "offline"}, config.Config{}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPriced(tt.names, &tt.cfg, &cost.Budget{MaxCost: tt.maxCost, MaxTokens: 1000})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkPriced() = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && (!strings.Contains(err.Error(), "-max-tokens") || !strings.Contains(err.Error(), "prices in "+config.FileName)) {
+				t.Errorf("error %q doesn't suggest -max-tokens or a price", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tanerincode/auto-test-generator/internal/config"
+	"github.com/tanerincode/auto-test-generator/internal/framework"
+	"github.com/tanerincode/auto-test-generator/internal/scan"
+)
+
+// runRun implements the run command. Test files are given relative to -root and run with the
+// test script of the package owning them; without any, every package runs its whole suite.
+func runRun(fs *flag.FlagSet, args []string) {
+	root := fs.String("root", ".", "Root directory of the project")
+	fwName := fs.String("fw", "auto", "Framework: auto, "+strings.Join(framework.Names(), ", "))
+	lf := addLogFlags(fs)
+	fs.Parse(args)
+	logger := lf.logger(os.Stderr)
+
+	forced, err := lookupFramework(*fwName)
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+
+	cfg, ws := loadProject(logger, *root)
+	ctx, stop := interruptContext(logger)
+	defer stop()
+
+	testPaths := make(map[string][]string)
+	if fs.NArg() == 0 {
+		for _, pkg := range projectPackages(ws) {
+			testPaths[pkg.Dir] = nil
+		}
+	} else {
+		for _, arg := range fs.Args() {
+			path := filepath.Join(*root, arg)
+			if _, err := os.Stat(path); err != nil {
+				fatalf(logger, "test file not found: %s", path)
+			}
+			pkg := ws.PackageFor(path)
+			testPaths[pkg.Dir] = append(testPaths[pkg.Dir], path)
+		}
+	}
+
+	var pkgs []scan.Package
+	for _, pkg := range ws.Packages {
+		if _, ok := testPaths[pkg.Dir]; ok {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	frameworks := detectFrameworks(logger, ws, pkgs, forced)
+	if len(frameworks) == 0 {
+		fatalf(logger, "failed to detect framework")
+	}
+
+	failed := 0
+	for _, err := range runTests(ctx, logger, cfg, ws, frameworks, testPaths) {
+		if err != nil {
+			failed++
+		}
+	}
+	if err := stopCause(ctx); err != nil {
+		fatalf(logger, "%v", err)
+	}
+	if failed > 0 {
+		fatalf(logger, "tests failed in %d package(s)", failed)
+	}
+}
+
+// runCoverage implements the coverage command.
+func runCoverage(fs *flag.FlagSet, args []string) {
+	root := fs.String("root", ".", "Root directory of the project")
+	fwName := fs.String("fw", "auto", "Framework: auto, "+strings.Join(framework.Names(), ", "))
+	minCoverage := fs.Float64("min", 0, "Minimum coverage threshold (0-100); fail if below")
+	lf := addLogFlags(fs)
+	fs.Parse(args)
+	logger := lf.logger(os.Stderr)
+
+	if *minCoverage < 0 || *minCoverage > 100 {
+		fatalf(logger, "min must be between 0 and 100")
+	}
+	forced, err := lookupFramework(*fwName)
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+
+	cfg, ws := loadProject(logger, *root)
+
+	frameworks := detectFrameworks(logger, ws, projectPackages(ws), forced)
+	if len(frameworks) == 0 {
+		fatalf(logger, "failed to detect framework")
+	}
+
+	ctx, stop := interruptContext(logger)
+	defer stop()
+
+	if _, err := checkCoverage(ctx, logger, cfg, ws, frameworks, *minCoverage); err != nil {
+		fatalf(logger, "%v", err)
+	}
+}
+
+// loadProject loads the configuration and workspace of the project at root, or exits.
+func loadProject(logger *slog.Logger, root string) (*config.Config, *scan.Workspace) {
+	cfg, err := config.Load(root)
+	if err != nil {
+		fatalf(logger, "failed to load config: %v", err)
+	}
+
+	ws, err := scan.LoadWorkspace(root)
+	if err != nil {
+		fatalf(logger, "failed to load workspace: %v", err)
+	}
+	if ws.IsMonorepo() {
+		logger.Info("found workspace packages", "count", len(ws.Packages))
+	}
+
+	return cfg, ws
+}
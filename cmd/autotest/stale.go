@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tanerincode/auto-test-generator/internal/output"
+	"github.com/tanerincode/auto-test-generator/internal/state"
+)
+
+// Fixes of stale tests.
+const (
+	fixRegenerate = "regenerate"
+	fixAugment    = "augment"
+	fixAccept     = "accept"
+)
+
+// runStale implements the stale command. It lists the generated tests whose source changed or
+// disappeared since they were written, and can regenerate or augment them, or accept them as
+// they are. Arguments after -- are passed on to generate.
+func runStale(fs *flag.FlagSet, args []string) {
+	root := fs.String("root", ".", "Root directory of the project")
+	jsonOut := fs.Bool("json", false, "Output the stale tests as JSON")
+	allowDirty := fs.Bool("allow-dirty", false, "With -fix regenerate or augment, allow fixing with a dirty working tree")
+	fix := fs.String("fix", "", "Fix the stale tests: regenerate (overwrite, keeping a .bak), augment (add tests for untested exports), or accept (mark as up to date); on a terminal, asks when not given")
+	lf := addLogFlags(fs)
+	fs.Parse(args)
+	logger := lf.logger(os.Stderr)
+
+	if *fix != "" && *fix != fixRegenerate && *fix != fixAugment && *fix != fixAccept {
+		fatalf(logger, "invalid fix: %s (must be regenerate, augment or accept)", *fix)
+	}
+
+	st, err := state.Load(*root)
+	if err != nil {
+		fatalf(logger, "%v", err)
+	}
+
+	stale := []state.Check{}
+	var sources []string // the stale sources that still exist
+	for _, c := range st.Check(*root) {
+		if c.Status == state.Fresh {
+			continue
+		}
+		stale = append(stale, c)
+		if c.Status != state.SourceDeleted {
+			sources = append(sources, c.Source)
+		}
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(stale); err != nil {
+			fatalf(logger, "failed to encode stale tests: %v", err)
+		}
+	} else {
+		printStale(stale, len(st.Files))
+	}
+
+	action := *fix
+	if action == "" && len(sources) > 0 && !*jsonOut && output.IsTerminal(os.Stdin) && output.IsTerminal(os.Stdout) {
+		action = askFix(len(sources))
+	}
+
+	genFlags := lf.args()
+	if *allowDirty {
+		genFlags = append(genFlags, "-allow-dirty")
+	}
+	switch action {
+	case fixRegenerate:
+		runGenerate(flag.NewFlagSet("generate", flag.ExitOnError), fixArgs(*root, append(genFlags, "-write-policy", "backup"), fs.Args(), sources))
+	case fixAugment:
+		runGenerate(flag.NewFlagSet("generate", flag.ExitOnError), fixArgs(*root, append(genFlags, "-mode", "augment"), fs.Args(), sources))
+	case fixAccept:
+		acceptStale(logger, st, *root, stale)
+	}
+}
+
+// printStale prints the stale tests out of total recorded ones.
+func printStale(stale []state.Check, total int) {
+	if len(stale) == 0 {
+		fmt.Printf("All %d generated test file(s) are up to date\n", total)
+		return
+	}
+
+	fmt.Printf("%d of %d generated test file(s) are stale:\n\n", len(stale), total)
+	for _, c := range stale {
+		fmt.Printf("  %-15s %s -> %s (%s, %s)\n", c.Status, c.Source, c.Test, c.Generator, c.Generated.Local().Format("2006-01-02 15:04"))
+	}
+	fmt.Println()
+}
+
+// askFix asks how to fix the tests of n stale sources, and returns the fix, or "" to leave them.
+func askFix(n int) string {
+	fmt.Printf("Fix the tests of %d source(s)? [r]egenerate, [a]ugment, a[c]cept, [s]kip: ", n)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "r", fixRegenerate:
+		return fixRegenerate
+	case "a", fixAugment:
+		return fixAugment
+	case "c", fixAccept:
+		return fixAccept
+	default:
+		return ""
+	}
+}
+
+// fixArgs returns the generate arguments fixing sources: the fix's flags, then the extra flags
+// given after --, which may override them, then the sources.
+func fixArgs(root string, fixFlags []string, extra []string, sources []string) []string {
+	args := append([]string{"-root", root}, fixFlags...)
+	args = append(args, extra...)
+	return append(args, sources...)
+}
+
+// acceptStale marks the stale tests as up to date: changed sources get their current hash, and
+// records of deleted files are dropped.
+func acceptStale(logger *slog.Logger, st *state.State, root string, stale []state.Check) {
+	for _, c := range stale {
+		switch c.Status {
+		case state.Changed:
+			content, err := os.ReadFile(filepath.Join(root, c.Source))
+			if err != nil {
+				fatalf(logger, "failed to read source file: %v", err)
+			}
+			c.Record.SourceHash = state.Hash(string(content))
+			st.Add(c.Record)
+		default:
+			st.Remove(c.Source)
+		}
+	}
+	if err := st.Save(root); err != nil {
+		fatalf(logger, "%v", err)
+	}
+	fmt.Printf("Accepted %d stale test file(s)\n", len(stale))
+}
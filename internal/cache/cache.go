@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tanerincode/auto-test-generator/internal/output"
+)
+
+// Dir is the directory autotest keeps its local state in, relative to the project root. It
+// ignores itself in git, so it never makes the working tree dirty.
+const Dir = ".autotest"
+
+// CacheDir is the directory of the generation cache, relative to the project root.
+var CacheDir = filepath.Join(Dir, "cache")
+
+// Key is everything a generated test depends on. Two generations with the same key would send
+// the same prompt to the same model.
+type Key struct {
+	Source string `json:"source"`
+	// Context is the resolved context sent with the source, such as the existing tests and the
+	// exports they miss in augment mode.
+	Context       string `json:"context"`
+	PromptVersion string `json:"promptVersion"`
+	Provider      string `json:"provider"`
+	Model         string `json:"model"`
+	Framework     string `json:"framework"`
+}
+
+// Hash returns the hex SHA-256 of the key.
+func (k Key) Hash() string {
+	content, _ := json.Marshal(k)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Entry is a cached generation.
+type Entry struct {
+	// Path is the source file the tests were generated for, relative to the project root.
+	Path     string    `json:"path"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	Created  time.Time `json:"created"`
+	Code     string    `json:"code"`
+}
+
+// Cache stores generated tests by the hash of their Key, one file per entry.
+type Cache struct {
+	root string
+	dir  string
+}
+
+// Open returns the cache of the project at root. Nothing is created until the first Put.
+func Open(root string) *Cache {
+	return &Cache{root: root, dir: filepath.Join(root, CacheDir)}
+}
+
+// path returns the file of the entry with hash.
+func (c *Cache) path(hash string) string {
+	return filepath.Join(c.dir, hash[:2], hash+".json")
+}
+
+// Get returns the entry of key, if there is one. A hit marks the entry as used, which keeps it
+// from being pruned.
+func (c *Cache) Get(key Key) (Entry, bool) {
+	path := c.path(key.Hash())
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return Entry{}, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return entry, true
+}
+
+// Put stores entry under key, replacing any earlier entry.
+func (c *Cache) Put(key Key, entry Entry) error {
+	content, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	if err := EnsureDir(c.root); err != nil {
+		return err
+	}
+	path := c.path(key.Hash())
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Concurrent runs never read half an entry
+	if err := output.WriteFileAtomic(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the number of entries and their total size in bytes.
+func (c *Cache) Stats() (int, int64, error) {
+	entries, size := 0, int64(0)
+	err := c.walk(func(path string, info fs.FileInfo) error {
+		entries++
+		size += info.Size()
+		return nil
+	})
+	return entries, size, err
+}
+
+// Prune removes the entries not used for olderThan, or all entries if olderThan is 0. It returns
+// the number of entries removed and the bytes freed.
+func (c *Cache) Prune(olderThan time.Duration) (int, int64, error) {
+	cutoff := time.Now().Add(-olderThan)
+	removed, freed := 0, int64(0)
+	err := c.walk(func(path string, info fs.FileInfo) error {
+		if olderThan > 0 && info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove cache entry: %w", err)
+		}
+		removed++
+		freed += info.Size()
+		return nil
+	})
+
+	// Drop the shard directories left empty; removing a non-empty one fails harmlessly
+	shards, _ := os.ReadDir(c.dir)
+	for _, shard := range shards {
+		if shard.IsDir() {
+			os.Remove(filepath.Join(c.dir, shard.Name()))
+		}
+	}
+	return removed, freed, err
+}
+
+// walk calls fn for every entry file. A missing cache has no entries.
+func (c *Cache) walk(fn func(path string, info fs.FileInfo) error) error {
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(path, info)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// EnsureDir creates the Dir of the project at root, with a .gitignore that ignores everything in
+// it, itself included.
+func EnsureDir(root string) error {
+	dir := filepath.Join(root, Dir)
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignore); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if err := os.WriteFile(ignore, []byte("# Local autotest state\n*\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", ignore, err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/tanerincode/auto-test-generator/internal/cost"
+)
+
+// FileName is the project configuration file, looked up in the project root.
+const FileName = ".autotest.json"
+
+// Config holds project-level settings that override autotest's detection.
+type Config struct {
+	// PackageManager overrides lockfile detection: npm, pnpm, yarn, or bun.
+	PackageManager string `json:"packageManager,omitempty"`
+	// WritePolicy is the default for -write-policy: skip, backup, or interactive.
+	WritePolicy string `json:"writePolicy,omitempty"`
+	// Providers is the default for -provider: a provider, or a comma-separated fallback chain.
+	Providers string `json:"providers,omitempty"`
+	// OpenAIBaseURL is the base URL of the OpenAI-compatible API of the openai provider.
+	OpenAIBaseURL string `json:"openaiBaseURL,omitempty"`
+	// OpenAIModel is the model the openai provider asks for.
+	OpenAIModel string `json:"openaiModel,omitempty"`
+	// AuggieConcurrency and OpenAIConcurrency limit the simultaneous calls to each provider.
+	AuggieConcurrency int `json:"auggieConcurrency,omitempty"`
+	OpenAIConcurrency int `json:"openaiConcurrency,omitempty"`
+	// AuggieRPM and OpenAIRPM limit the calls to each provider per minute; unset for no limit.
+	AuggieRPM int `json:"auggieRPM,omitempty"`
+	OpenAIRPM int `json:"openaiRPM,omitempty"`
+	// Prices adds to and overrides the built-in price table, keyed by model name: an openai
+	// model, or "auggie".
+	Prices cost.Prices `json:"prices,omitempty"`
+}
+
+// Load reads the configuration from root. A missing file yields an empty configuration.
+func Load(root string) (*Config, error) {
+	path := filepath.Join(root, FileName)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return &cfg, nil
+}
+
+// fields maps the setting names used in the configuration file to their values, each a *string
+// or an *int.
+func (c *Config) fields() map[string]any {
+	return map[string]any{
+		"packageManager":    &c.PackageManager,
+		"writePolicy":       &c.WritePolicy,
+		"providers":         &c.Providers,
+		"openaiBaseURL":     &c.OpenAIBaseURL,
+		"openaiModel":       &c.OpenAIModel,
+		"auggieConcurrency": &c.AuggieConcurrency,
+		"auggieRPM":         &c.AuggieRPM,
+		"openaiConcurrency": &c.OpenAIConcurrency,
+		"openaiRPM":         &c.OpenAIRPM,
+	}
+}
+
+// Keys returns the setting names, sorted.
+func Keys() []string {
+	var keys []string
+	for key := range (&Config{}).fields() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns the value of the setting key, or "" if it is unset.
+func (c *Config) Get(key string) (string, error) {
+	field, ok := c.fields()[key]
+	if !ok {
+		return "", fmt.Errorf("unknown setting: %s (one of %v)", key, Keys())
+	}
+	switch field := field.(type) {
+	case *int:
+		if *field == 0 {
+			return "", nil
+		}
+		return strconv.Itoa(*field), nil
+	default:
+		return *field.(*string), nil
+	}
+}
+
+// Set changes the setting key. An empty value unsets it.
+func (c *Config) Set(key string, value string) error {
+	field, ok := c.fields()[key]
+	if !ok {
+		return fmt.Errorf("unknown setting: %s (one of %v)", key, Keys())
+	}
+	switch field := field.(type) {
+	case *int:
+		if value == "" {
+			*field = 0
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid %s: %s (must be a positive number)", key, value)
+		}
+		*field = n
+	default:
+		*field.(*string) = value
+	}
+	return nil
+}
+
+// Save writes the configuration to root.
+func Save(root string, cfg *Config) error {
+	content, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+
+	path := filepath.Join(root, FileName)
+	if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package cost
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Price is what a model costs, in US dollars per million tokens.
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// Prices maps model names to their prices.
+type Prices map[string]Price
+
+// DefaultPrices are the list prices of common OpenAI models. The configuration can override them
+// and add others, such as a price for "auggie".
+var DefaultPrices = Prices{
+	"gpt-4o":       {Prompt: 2.50, Completion: 10.00},
+	"gpt-4o-mini":  {Prompt: 0.15, Completion: 0.60},
+	"gpt-4.1":      {Prompt: 2.00, Completion: 8.00},
+	"gpt-4.1-mini": {Prompt: 0.40, Completion: 1.60},
+	"gpt-4.1-nano": {Prompt: 0.10, Completion: 0.40},
+	"o4-mini":      {Prompt: 1.10, Completion: 4.40},
+}
+
+// With returns the prices of p, with those of overrides added or replacing them.
+func (p Prices) With(overrides Prices) Prices {
+	merged := make(Prices, len(p)+len(overrides))
+	for model, price := range p {
+		merged[model] = price
+	}
+	for model, price := range overrides {
+		merged[model] = price
+	}
+	return merged
+}
+
+// Cost returns the price of the given tokens of model, and false if model has no price.
+func (p Prices) Cost(model string, promptTokens int, completionTokens int) (float64, bool) {
+	price, ok := p[model]
+	if !ok {
+		return 0, false
+	}
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1e6, true
+}
+
+// Format formats a cost in US dollars, with enough decimals for the fractions of a cent a single
+// file costs.
+func Format(cost float64) string {
+	return fmt.Sprintf("$%.4f", cost)
+}
+
+// Budget tracks the tokens and money a run spends against its limits. Zero limits are
+// unlimited. It is safe for concurrent use.
+type Budget struct {
+	MaxCost   float64
+	MaxTokens int
+
+	mu     sync.Mutex
+	cost   float64
+	tokens int
+}
+
+// Add records spending.
+func (b *Budget) Add(tokens int, cost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += tokens
+	b.cost += cost
+}
+
+// Spent returns the tokens and money spent so far.
+func (b *Budget) Spent() (int, float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens, b.cost
+}
+
+// Err returns an error once a limit is reached, or nil while the budget lasts.
+func (b *Budget) Err() error {
+	tokens, cost := b.Spent()
+	switch {
+	case b.MaxTokens > 0 && tokens >= b.MaxTokens:
+		return fmt.Errorf("token budget of %d reached (%d used)", b.MaxTokens, tokens)
+	case b.MaxCost > 0 && cost >= b.MaxCost:
+		return fmt.Errorf("cost budget of %s reached (%s spent)", Format(b.MaxCost), Format(cost))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package cost
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestPricesCost(t *testing.T) {
+	prices := Prices{"m": {Prompt: 2, Completion: 10}}
+
+	got, ok := prices.Cost("m", 1000, 500)
+	if !ok || got != 0.007 {
+		t.Errorf("Cost(m) = %v, %v; want 0.007, true", got, ok)
+	}
+	if got, ok := prices.Cost("unknown", 1000, 500); ok || got != 0 {
+		t.Errorf("Cost(unknown) = %v, %v; want 0, false", got, ok)
+	}
+}
+
+func TestPricesWith(t *testing.T) {
+	base := Prices{"a": {Prompt: 1}, "b": {Prompt: 2}}
+	merged := base.With(Prices{"b": {Prompt: 3}, "auggie": {Prompt: 4}})
+
+	if merged["a"].Prompt != 1 || merged["b"].Prompt != 3 || merged["auggie"].Prompt != 4 {
+		t.Errorf("merged = %v", merged)
+	}
+	if base["b"].Prompt != 2 || len(base) != 2 {
+		t.Errorf("With changed the prices it was called on: %v", base)
+	}
+}
+
+func TestBudgetErr(t *testing.T) {
+	tests := []struct {
+		name    string
+		budget  *Budget
+		tokens  int
+		cost    float64
+		reached bool
+	}{
+		{"unlimited", &Budget{}, 1000000, 100, false},
+		{"below token limit", &Budget{MaxTokens: 100}, 99, 0, false},
+		{"at token limit", &Budget{MaxTokens: 100}, 100, 0, true},
+		{"below cost limit", &Budget{MaxCost: 1}, 0, 0.99, false},
+		{"at cost limit", &Budget{MaxCost: 1}, 0, 1, true},
+		{"one of both limits", &Budget{MaxCost: 1, MaxTokens: 100}, 10, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.budget.Add(tt.tokens, tt.cost)
+			if err := tt.budget.Err(); (err != nil) != tt.reached {
+				t.Errorf("Err() = %v, want reached %v", err, tt.reached)
+			}
+		})
+	}
+}
+
+func TestBudgetConcurrentAdd(t *testing.T) {
+	var b Budget
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Add(10, 0.5)
+		}()
+	}
+	wg.Wait()
+
+	if tokens, spent := b.Spent(); tokens != 1000 || spent != 50 {
+		t.Errorf("Spent() = %d, %v; want 1000, 50", tokens, spent)
+	}
+}
@@ -0,0 +1,369 @@
+package diff
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change.
+const context = 3
+
+type op int
+
+const (
+	opEqual op = iota
+	opDelete
+	opInsert
+)
+
+// edit is one line of an edit script.
+type edit struct {
+	op   op
+	line string
+}
+
+// Unified returns a unified diff turning oldText into newText, with the given file names in the
+// --- and +++ headers. It returns "" if the texts are equal.
+func Unified(oldName string, newName string, oldText string, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	edits := lineDiff(splitLines(oldText), splitLines(newText))
+
+	var sb strings.Builder
+	sb.WriteString("--- " + oldName + "\n")
+	sb.WriteString("+++ " + newName + "\n")
+	for _, h := range hunks(edits) {
+		sb.WriteString(h)
+	}
+	return sb.String()
+}
+
+// GitFile returns a git-style diff for one file that `git apply` accepts. path is relative to the
+// directory the patch is applied from; isNew marks a file that does not exist yet.
+func GitFile(path string, oldText string, newText string, isNew bool) string {
+	if oldText == newText && !isNew {
+		return ""
+	}
+	path = filepath.ToSlash(path)
+
+	var sb strings.Builder
+	sb.WriteString("diff --git a/" + path + " b/" + path + "\n")
+	oldName := "a/" + path
+	if isNew {
+		sb.WriteString("new file mode 100644\n")
+		oldName = "/dev/null"
+		if newText == "" {
+			// An empty file has no hunk; git marks it with the hash of the empty blob instead
+			sb.WriteString("index 0000000..e69de29\n")
+			return sb.String()
+		}
+	}
+
+	sb.WriteString(Unified(oldName, "b/"+path, oldText, newText))
+	return sb.String()
+}
+
+// Stats returns the number of added and removed lines between oldText and newText.
+func Stats(oldText string, newText string) (added int, removed int) {
+	for _, e := range lineDiff(splitLines(oldText), splitLines(newText)) {
+		switch e.op {
+		case opInsert:
+			added++
+		case opDelete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Range is a span of lines, numbered from 1, End included.
+type Range struct {
+	Start int
+	End   int
+}
+
+// Contains reports whether line is in r.
+func (r Range) Contains(line int) bool {
+	return line >= r.Start && line <= r.End
+}
+
+// String formats r as "3" or "3-7".
+func (r Range) String() string {
+	if r.Start == r.End {
+		return fmt.Sprint(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// ChangedLines returns the lines of newText that differ from oldText, in order. Where lines were
+// only removed, the line after them counts as changed, or the last line at the end of the text.
+func ChangedLines(oldText string, newText string) []Range {
+	var ranges []Range
+	mark := func(line int) {
+		if n := len(ranges); n > 0 && ranges[n-1].End >= line-1 {
+			ranges[n-1].End = max(ranges[n-1].End, line)
+			return
+		}
+		ranges = append(ranges, Range{Start: line, End: line})
+	}
+
+	newLines := splitLines(newText)
+	line := 1 // the next line of newText
+	for _, e := range lineDiff(splitLines(oldText), newLines) {
+		switch e.op {
+		case opEqual:
+			line++
+		case opInsert:
+			mark(line)
+			line++
+		case opDelete:
+			if len(newLines) > 0 {
+				mark(min(line, len(newLines)))
+			}
+		}
+	}
+	return ranges
+}
+
+// LineCount returns the number of lines in text, counting a final line without a newline.
+func LineCount(text string) int {
+	return len(splitLines(text))
+}
+
+// Colorize adds ANSI colors to a unified diff for terminal output.
+func Colorize(d string) string {
+	const (
+		reset = "\033[0m"
+		bold  = "\033[1m"
+		red   = "\033[31m"
+		green = "\033[32m"
+		cyan  = "\033[36m"
+	)
+
+	lines := strings.SplitAfter(d, "\n")
+	var sb strings.Builder
+	for _, line := range lines {
+		body := strings.TrimSuffix(line, "\n")
+		nl := line[len(body):]
+		switch {
+		case strings.HasPrefix(body, "+++"), strings.HasPrefix(body, "---"), strings.HasPrefix(body, "diff "):
+			sb.WriteString(bold + body + reset + nl)
+		case strings.HasPrefix(body, "@@"):
+			sb.WriteString(cyan + body + reset + nl)
+		case strings.HasPrefix(body, "+"):
+			sb.WriteString(green + body + reset + nl)
+		case strings.HasPrefix(body, "-"):
+			sb.WriteString(red + body + reset + nl)
+		default:
+			sb.WriteString(line)
+		}
+	}
+	return sb.String()
+}
+
+// splitLines splits text into lines, each keeping its trailing newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineDiff computes a shortest edit script from a to b with Myers' algorithm.
+func lineDiff(a []string, b []string) []edit {
+	// Common prefix and suffix are cheap to peel off and keep the search small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []edit
+	for _, line := range a[:prefix] {
+		edits = append(edits, edit{opEqual, line})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, edit{opEqual, line})
+	}
+	return edits
+}
+
+// myers returns the edit script for a and b, which share no common prefix or suffix.
+func myers(a []string, b []string) []edit {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		var edits []edit
+		for _, line := range a {
+			edits = append(edits, edit{opDelete, line})
+		}
+		for _, line := range b {
+			edits = append(edits, edit{opInsert, line})
+		}
+		return edits
+	}
+
+	max := n + m
+	off := max
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+off] < v[k+1+off]) {
+				x = v[k+1+off]
+			} else {
+				x = v[k-1+off] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+off] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace backwards from (n, m) to recover the path
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[k-1+off] < v[k+1+off]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+off]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{opEqual, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{opInsert, b[y-1]})
+			} else {
+				edits = append(edits, edit{opDelete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// hunks groups an edit script into unified diff hunks with surrounding context.
+func hunks(edits []edit) []string {
+	var result []string
+
+	for i := 0; i < len(edits); {
+		// Find the next change
+		for i < len(edits) && edits[i].op == opEqual {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk while changes are close enough to share context
+		end := i
+		for end < len(edits) {
+			if edits[end].op != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].op == opEqual {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+
+		result = append(result, formatHunk(edits, start, end))
+		i = end
+	}
+
+	return result
+}
+
+// formatHunk renders edits[start:end] as a hunk with its header.
+func formatHunk(edits []edit, start int, end int) string {
+	oldLine, newLine := 1, 1
+	for _, e := range edits[:start] {
+		if e.op != opInsert {
+			oldLine++
+		}
+		if e.op != opDelete {
+			newLine++
+		}
+	}
+
+	var body strings.Builder
+	oldCount, newCount := 0, 0
+	for _, e := range edits[start:end] {
+		prefix := " "
+		switch e.op {
+		case opDelete:
+			prefix = "-"
+			oldCount++
+		case opInsert:
+			prefix = "+"
+			newCount++
+		default:
+			oldCount++
+			newCount++
+		}
+		body.WriteString(prefix + e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			body.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+
+	return fmt.Sprintf("@@ -%s +%s @@\n%s", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount), body.String())
+}
+
+// hunkRange formats a hunk range; empty ranges point at the line before them.
+func hunkRange(line int, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", line-1)
+	case 1:
+		return fmt.Sprintf("%d", line)
+	default:
+		return fmt.Sprintf("%d,%d", line, count)
+	}
+}
@@ -0,0 +1,195 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     string
+		b     string
+		edits int
+	}{
+		{"equal", "abc", "abc", 0},
+		{"empty to text", "", "ab", 2},
+		{"text to empty", "ab", "", 2},
+		{"replace middle", "abc", "axc", 2},
+		{"move first to last", "xab", "abx", 2},
+		{"classic", "abcabba", "cbabac", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := strings.Split(tt.a, ""), strings.Split(tt.b, "")
+			edits := lineDiff(a, b)
+
+			var gotA, gotB []string
+			changes := 0
+			for _, e := range edits {
+				if e.op != opInsert {
+					gotA = append(gotA, e.line)
+				}
+				if e.op != opDelete {
+					gotB = append(gotB, e.line)
+				}
+				if e.op != opEqual {
+					changes++
+				}
+			}
+			if strings.Join(gotA, "") != tt.a || strings.Join(gotB, "") != tt.b {
+				t.Errorf("edits turn %q into %q, want %q into %q", strings.Join(gotA, ""), strings.Join(gotB, ""), tt.a, tt.b)
+			}
+			if changes != tt.edits {
+				t.Errorf("got %d changed lines, want %d", changes, tt.edits)
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{"equal", "a\n", "a\n", ""},
+		{"empty to text", "", "x\ny\n", "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n"},
+		{"text to empty", "x\ny\n", "", "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-x\n-y\n"},
+		{
+			"no trailing newline",
+			"a\nb",
+			"a\nc",
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			"trailing newline added",
+			"a",
+			"a\n",
+			"--- a\n+++ b\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n",
+		},
+		{
+			"context around change",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			"--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("a", "b", tt.old, tt.new); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHunkGrouping(t *testing.T) {
+	// numbered returns 20 distinct lines, with the lines numbered changed replaced
+	numbered := func(changed ...int) string {
+		var sb strings.Builder
+		for i := 1; i <= 20; i++ {
+			line := string(rune('a'+i-1)) + "\n"
+			for _, c := range changed {
+				if c == i {
+					line = "changed\n"
+				}
+			}
+			sb.WriteString(line)
+		}
+		return sb.String()
+	}
+
+	tests := []struct {
+		name    string
+		changed []int
+		want    []string
+	}{
+		{"one change", []int{10}, []string{"@@ -7,7 +7,7 @@"}},
+		{"adjacent lines", []int{10, 11}, []string{"@@ -7,8 +7,8 @@"}},
+		{"shared context", []int{5, 12}, []string{"@@ -2,14 +2,14 @@"}},
+		{"separate hunks", []int{5, 13}, []string{"@@ -2,7 +2,7 @@", "@@ -10,7 +10,7 @@"}},
+		{"at the edges", []int{1, 20}, []string{"@@ -1,4 +1,4 @@", "@@ -17,4 +17,4 @@"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers []string
+			for _, line := range strings.Split(Unified("a", "b", numbered(), numbered(tt.changed...)), "\n") {
+				if strings.HasPrefix(line, "@@") {
+					headers = append(headers, line)
+				}
+			}
+			if !reflect.DeepEqual(headers, tt.want) {
+				t.Errorf("got hunks %q, want %q", headers, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		old   string
+		new   string
+		isNew bool
+		want  string
+	}{
+		{"unchanged", "a\n", "a\n", false, ""},
+		{
+			"changed",
+			"a\n",
+			"b\n",
+			false,
+			"diff --git a/src/x.test.ts b/src/x.test.ts\n--- a/src/x.test.ts\n+++ b/src/x.test.ts\n@@ -1 +1 @@\n-a\n+b\n",
+		},
+		{
+			"new",
+			"",
+			"a\n",
+			true,
+			"diff --git a/src/x.test.ts b/src/x.test.ts\nnew file mode 100644\n--- /dev/null\n+++ b/src/x.test.ts\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			"new and empty",
+			"",
+			"",
+			true,
+			"diff --git a/src/x.test.ts b/src/x.test.ts\nnew file mode 100644\nindex 0000000..e69de29\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GitFile("src/x.test.ts", tt.old, tt.new, tt.isNew); got != tt.want {
+				t.Errorf("GitFile() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangedLines(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want []Range
+	}{
+		{"equal", "a\nb\n", "a\nb\n", nil},
+		{"empty to text", "", "a\nb\n", []Range{{1, 2}}},
+		{"text to empty", "a\nb\n", "", nil},
+		{"removed line", "a\nb\nc\n", "a\nc\n", []Range{{2, 2}}},
+		{"removed last line", "a\nb\n", "a\n", []Range{{1, 1}}},
+		{"trailing newline added", "a\nb", "a\nb\n", []Range{{2, 2}}},
+		{"adjacent lines", "a\nb\nc\n", "A\nB\nc\n", []Range{{1, 2}}},
+		{"separate lines", "a\nb\nc\nd\n", "A\nb\nC\nd\n", []Range{{1, 1}, {3, 3}}},
+		{"replaced with more lines", "a\nb\nc\n", "a\nx\ny\nc\n", []Range{{2, 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ChangedLines(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChangedLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package exec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/tanerincode/auto-test-generator/internal/framework"
+)
+
+var (
+	// ErrNoFramework is returned when no signal points at any test framework.
+	ErrNoFramework = errors.New("no test framework detected")
+	// ErrAmbiguousFramework is returned when the strongest signals point at different frameworks.
+	ErrAmbiguousFramework = errors.New("conflicting test framework signals")
+)
+
+// maxSampledTestFiles bounds how many existing test files are read for their imports.
+const maxSampledTestFiles = 50
+
+// Confidence ranks how strongly a signal identifies a framework.
+type Confidence int
+
+const (
+	// ConfidenceLow comes from a package in a lockfile, which may be a transitive dependency.
+	ConfidenceLow Confidence = iota + 1
+	// ConfidenceMedium comes from a direct dependency in package.json.
+	ConfidenceMedium
+	// ConfidenceHigh comes from the imports of existing test files.
+	ConfidenceHigh
+	// ConfidenceCertain comes from a framework config file or the test script.
+	ConfidenceCertain
+)
+
+// String returns the confidence level name.
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceMedium:
+		return "medium"
+	case ConfidenceHigh:
+		return "high"
+	case ConfidenceCertain:
+		return "certain"
+	default:
+		return "none"
+	}
+}
+
+// Signal is one piece of evidence pointing at a framework.
+type Signal struct {
+	Framework  string
+	Confidence Confidence
+	Source     string
+}
+
+// String describes the signal, e.g. "vitest (certain): vitest.config.ts".
+func (s Signal) String() string {
+	return fmt.Sprintf("%s (%s): %s", s.Framework, s.Confidence, s.Source)
+}
+
+// Detection is the outcome of framework detection.
+type Detection struct {
+	Framework  framework.Framework
+	Confidence Confidence
+	// Reason is the signal that decided the framework.
+	Reason Signal
+	// Signals holds every signal found, strongest first.
+	Signals []Signal
+}
+
+// DetectFramework detects the test framework of the package at root. It collects signals from
+// config files, the test script, existing test files' imports, package.json dependencies and
+// lockfiles, and picks the framework backed by the strongest ones. If the strongest signals
+// point at different frameworks it returns ErrAmbiguousFramework rather than guess.
+func DetectFramework(root string) (*Detection, error) {
+	pkgPath := filepath.Join(root, "package.json")
+	content, err := os.ReadFile(pkgPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	var pkg map[string]interface{}
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+
+	var signals []Signal
+	signals = append(signals, configSignals(root, pkg)...)
+	signals = append(signals, scriptSignals(pkg)...)
+	signals = append(signals, importSignals(root)...)
+	signals = append(signals, dependencySignals(pkg)...)
+	signals = append(signals, lockfileSignals(root)...)
+
+	if len(signals) == 0 {
+		return nil, fmt.Errorf("%w (one of %s required)", ErrNoFramework, strings.Join(framework.Names(), ", "))
+	}
+
+	sort.SliceStable(signals, func(i, j int) bool {
+		return signals[i].Confidence > signals[j].Confidence
+	})
+
+	// Only the strongest tier decides; weaker signals are reported but never override it
+	top := signals[0].Confidence
+	candidates := make(map[string]bool)
+	var deciding []string
+	for _, signal := range signals {
+		if signal.Confidence != top {
+			break
+		}
+		candidates[signal.Framework] = true
+		deciding = append(deciding, signal.String())
+	}
+
+	if len(candidates) > 1 {
+		return nil, fmt.Errorf("%w: %s; use -fw to choose one", ErrAmbiguousFramework, strings.Join(deciding, "; "))
+	}
+
+	fw, err := framework.Lookup(signals[0].Framework)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Detection{
+		Framework:  fw,
+		Confidence: top,
+		Reason:     signals[0],
+		Signals:    signals,
+	}, nil
+}
+
+// configSignals looks for framework config files and the "jest" key of package.json.
+func configSignals(root string, pkg map[string]interface{}) []Signal {
+	var signals []Signal
+	for _, fw := range framework.All() {
+		for _, pattern := range fw.ConfigFiles {
+			matches, _ := doublestar.FilepathGlob(filepath.Join(root, pattern))
+			for _, match := range matches {
+				rel, _ := filepath.Rel(root, match)
+				signals = append(signals, Signal{Framework: fw.Name, Confidence: ConfidenceCertain, Source: rel})
+			}
+		}
+	}
+
+	if _, ok := pkg["jest"]; ok {
+		signals = append(signals, Signal{Framework: "jest", Confidence: ConfidenceCertain, Source: `package.json "jest" config`})
+	}
+
+	return signals
+}
+
+// scriptSignals matches the test script's commands against each framework's markers.
+func scriptSignals(pkg map[string]interface{}) []Signal {
+	scripts, _ := pkg["scripts"].(map[string]interface{})
+	script, ok := scripts["test"].(string)
+	if !ok {
+		return nil
+	}
+
+	tokens := strings.Fields(regexp.MustCompile(`&&|\|\||[;|]`).ReplaceAllString(script, " ; "))
+
+	var signals []Signal
+	for _, fw := range framework.All() {
+		for _, marker := range fw.ScriptMarkers {
+			if containsTokens(tokens, strings.Fields(marker)) {
+				signals = append(signals, Signal{Framework: fw.Name, Confidence: ConfidenceCertain, Source: "scripts.test: " + script})
+				break
+			}
+		}
+	}
+
+	return signals
+}
+
+// containsTokens reports whether want appears as a consecutive run of whole tokens.
+func containsTokens(tokens []string, want []string) bool {
+	for i := 0; i+len(want) <= len(tokens); i++ {
+		match := true
+		for j, token := range want {
+			if tokens[i+j] != token {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// importPattern matches module specifiers of import statements and require calls.
+var importPattern = regexp.MustCompile(`(?:from|import|require\()\s*['"]([^'"]+)['"]`)
+
+// skippedDirs are directories of installed, vendored or built code, whose test files aren't the
+// package's own.
+var skippedDirs = map[string]bool{
+	"node_modules": true, "bower_components": true, "jspm_packages": true, "vendor": true,
+	"dist": true, "build": true, "out": true, "coverage": true,
+}
+
+// importSignals samples existing test files of the package at root and counts the framework
+// modules they import. Nested packages, hidden directories and skippedDirs are left out.
+func importSignals(root string) []Signal {
+	counts := make(map[string]int)
+	sampled := 0
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			if skippedDirs[d.Name()] || strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "package.json")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if sampled >= maxSampledTestFiles {
+			return filepath.SkipAll
+		}
+
+		name := d.Name()
+		if !strings.Contains(name, ".test.") && !strings.Contains(name, ".spec.") {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		sampled++
+
+		seen := make(map[string]bool)
+		for _, match := range importPattern.FindAllStringSubmatch(string(content), -1) {
+			for _, fw := range framework.All() {
+				for _, source := range fw.ImportSources {
+					if match[1] == source && !seen[fw.Name] {
+						seen[fw.Name] = true
+						counts[fw.Name]++
+					}
+				}
+			}
+		}
+		return nil
+	})
+
+	var signals []Signal
+	for _, fw := range framework.All() {
+		if n := counts[fw.Name]; n > 0 {
+			signals = append(signals, Signal{
+				Framework:  fw.Name,
+				Confidence: ConfidenceHigh,
+				Source:     fmt.Sprintf("%d of %d sampled test file(s) import it", n, sampled),
+			})
+		}
+	}
+
+	return signals
+}
+
+// dependencySignals checks dependencies and devDependencies for framework packages.
+func dependencySignals(pkg map[string]interface{}) []Signal {
+	var signals []Signal
+	for _, field := range []string{"devDependencies", "dependencies"} {
+		deps, _ := pkg[field].(map[string]interface{})
+		for _, fw := range framework.All() {
+			for _, name := range fw.Packages {
+				if _, ok := deps[name]; ok {
+					signals = append(signals, Signal{Framework: fw.Name, Confidence: ConfidenceMedium, Source: field + ": " + name})
+				}
+			}
+		}
+	}
+	return signals
+}
+
+// lockfileSignals checks lockfiles for framework packages.
+func lockfileSignals(root string) []Signal {
+	var signals []Signal
+	for _, fw := range framework.All() {
+		for _, name := range fw.Packages {
+			if lockfile, ok := hasFrameworkInLockfile(root, name); ok {
+				signals = append(signals, Signal{Framework: fw.Name, Confidence: ConfidenceLow, Source: lockfile + ": " + name})
+				break
+			}
+		}
+	}
+	return signals
+}
+
+// hasFrameworkInLockfile checks if a package is an entry of one of the lockfiles and returns
+// the lockfile name. Entries are matched by exact package name, so "jest" does not match "jest-diff".
+func hasFrameworkInLockfile(root string, name string) (string, bool) {
+	quoted := regexp.QuoteMeta(name)
+	patterns := map[string]*regexp.Regexp{
+		// "  /jest@29.7.0:" (pnpm v6-8), "  jest@29.7.0:" (v9), "      jest:" (importers)
+		"pnpm-lock.yaml": regexp.MustCompile(`(?m)^\s*['"]?/?` + quoted + `(@|:|['"]:)`),
+		// "jest@^29.7.0:" or "\"jest@^29.7.0\", jest@^29:"
+		"yarn.lock": regexp.MustCompile(`(?m)^"?` + quoted + `@`),
+		// "node_modules/jest": {
+		"package-lock.json": regexp.MustCompile(`"node_modules/` + quoted + `"`),
+	}
+
+	for _, lockfile := range []string{"pnpm-lock.yaml", "yarn.lock", "package-lock.json"} {
+		content, err := os.ReadFile(filepath.Join(root, lockfile))
+		if err != nil {
+			continue
+		}
+		if patterns[lockfile].Match(content) {
+			return lockfile, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,148 @@
+package exec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectFramework(t *testing.T) {
+	const vitestTest = "import { describe, it } from 'vitest';\n"
+	const jestTest = "import { describe, it } from '@jest/globals';\n"
+
+	tests := []struct {
+		name       string
+		files      map[string]string
+		want       string
+		confidence Confidence
+		err        error
+	}{
+		{
+			name: "config file beats dependencies",
+			files: map[string]string{
+				"package.json":     `{"devDependencies": {"jest": "^29"}}`,
+				"vitest.config.ts": "",
+			},
+			want:       "vitest",
+			confidence: ConfidenceCertain,
+		},
+		{
+			name:       "test script",
+			files:      map[string]string{"package.json": `{"scripts": {"test": "jest --coverage"}}`},
+			want:       "jest",
+			confidence: ConfidenceCertain,
+		},
+		{
+			name: "test file imports beat dependencies",
+			files: map[string]string{
+				"package.json":     `{"devDependencies": {"jest": "^29"}}`,
+				"src/a.test.ts":    vitestTest,
+				"src/b.spec.ts":    vitestTest,
+				"src/c.helpers.ts": jestTest,
+			},
+			want:       "vitest",
+			confidence: ConfidenceHigh,
+		},
+		{
+			name:       "dependency",
+			files:      map[string]string{"package.json": `{"devDependencies": {"mocha": "^10"}}`},
+			want:       "mocha",
+			confidence: ConfidenceMedium,
+		},
+		{
+			name: "lockfile",
+			files: map[string]string{
+				"package.json":      `{}`,
+				"package-lock.json": `{"packages": {"node_modules/vitest": {}}}`,
+			},
+			want:       "vitest",
+			confidence: ConfidenceLow,
+		},
+		{
+			name: "lockfile matches whole package names",
+			files: map[string]string{
+				"package.json":      `{}`,
+				"package-lock.json": `{"packages": {"node_modules/jest-diff": {}}}`,
+			},
+			err: ErrNoFramework,
+		},
+		{
+			name: "conflicting config files",
+			files: map[string]string{
+				"package.json":     `{}`,
+				"vitest.config.ts": "",
+				"jest.config.js":   "",
+			},
+			err: ErrAmbiguousFramework,
+		},
+		{
+			name: "tied test file imports",
+			files: map[string]string{
+				"package.json":  `{"devDependencies": {"vitest": "^1"}}`,
+				"src/a.test.ts": vitestTest,
+				"src/b.test.ts": jestTest,
+			},
+			err: ErrAmbiguousFramework,
+		},
+		{
+			name:  "nothing",
+			files: map[string]string{"package.json": `{"dependencies": {"react": "^18"}}`},
+			err:   ErrNoFramework,
+		},
+		{
+			name: "vendored and built test files",
+			files: map[string]string{
+				"package.json":                    `{"devDependencies": {"vitest": "^1"}}`,
+				"node_modules/lib/a.test.js":      jestTest,
+				"vendor/lib/a.test.js":            jestTest,
+				"dist/a.test.js":                  jestTest,
+				".cache/a.test.js":                jestTest,
+				"node_modules/lib/b.spec.js":      jestTest,
+				"bower_components/lib/c.spec.js":  jestTest,
+				"jspm_packages/npm/lib/d.spec.js": jestTest,
+			},
+			want:       "vitest",
+			confidence: ConfidenceMedium,
+		},
+		{
+			name: "test files of nested packages",
+			files: map[string]string{
+				"package.json":                    `{"devDependencies": {"vitest": "^1"}}`,
+				"packages/a/package.json":         `{}`,
+				"packages/a/src/a.test.ts":        jestTest,
+				"packages/a/src/nested/b.test.ts": jestTest,
+			},
+			want:       "vitest",
+			confidence: ConfidenceMedium,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for path, content := range tt.files {
+				path = filepath.Join(root, path)
+				if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			detection, err := DetectFramework(root)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("DetectFramework error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DetectFramework: %v", err)
+			}
+			if detection.Framework.Name != tt.want || detection.Confidence != tt.confidence {
+				t.Errorf("detected %s (%s), want %s (%s); signals: %v", detection.Framework.Name, detection.Confidence, tt.want, tt.confidence, detection.Signals)
+			}
+		})
+	}
+}
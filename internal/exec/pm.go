@@ -0,0 +1,140 @@
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PackageManager identifies the Node.js package manager used to run scripts and binaries.
+type PackageManager string
+
+const (
+	NPM  PackageManager = "npm"
+	PNPM PackageManager = "pnpm"
+	Yarn PackageManager = "yarn"
+	Bun  PackageManager = "bun"
+)
+
+// lockfiles maps lockfile names to the package manager that writes them, in lookup order.
+var lockfiles = []struct {
+	name string
+	pm   PackageManager
+}{
+	{"pnpm-lock.yaml", PNPM},
+	{"yarn.lock", Yarn},
+	{"bun.lockb", Bun},
+	{"bun.lock", Bun},
+	{"package-lock.json", NPM},
+	{"npm-shrinkwrap.json", NPM},
+}
+
+// ParsePackageManager validates a package manager name.
+func ParsePackageManager(name string) (PackageManager, error) {
+	switch pm := PackageManager(strings.ToLower(name)); pm {
+	case NPM, PNPM, Yarn, Bun:
+		return pm, nil
+	default:
+		return "", fmt.Errorf("invalid package manager: %s (must be npm, pnpm, yarn, or bun)", name)
+	}
+}
+
+// DetectPackageManager detects the package manager for the package at dir, looking at the
+// packageManager field of package.json and then at lockfiles, first in dir and then in the
+// workspace root. Defaults to npm.
+func DetectPackageManager(dir string, root string) PackageManager {
+	dirs := []string{dir}
+	if filepath.Clean(root) != filepath.Clean(dir) {
+		dirs = append(dirs, root)
+	}
+
+	for _, d := range dirs {
+		if pm, ok := packageManagerField(d); ok {
+			return pm
+		}
+		for _, lockfile := range lockfiles {
+			if _, err := os.Stat(filepath.Join(d, lockfile.name)); err == nil {
+				return lockfile.pm
+			}
+		}
+	}
+
+	return NPM
+}
+
+// packageManagerField reads the corepack "packageManager" field (e.g. "pnpm@8.6.0") from package.json.
+func packageManagerField(dir string) (PackageManager, bool) {
+	content, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return "", false
+	}
+
+	var pkg struct {
+		PackageManager string `json:"packageManager"`
+	}
+	if err := json.Unmarshal(content, &pkg); err != nil || pkg.PackageManager == "" {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(pkg.PackageManager, "@")
+	pm, err := ParsePackageManager(name)
+	if err != nil {
+		return "", false
+	}
+	return pm, true
+}
+
+// ScriptCommand returns the command that runs a package.json script with extra arguments.
+func (pm PackageManager) ScriptCommand(script string, args ...string) []string {
+	var cmd []string
+	switch pm {
+	case PNPM:
+		cmd = []string{"pnpm", "run", script}
+	case Yarn:
+		// yarn forwards arguments as-is and warns about a "--" separator
+		return append([]string{"yarn", "run", script}, args...)
+	case Bun:
+		// "bun run" is needed since "bun test" is Bun's own test runner, not the script
+		return append([]string{"bun", "run", script}, args...)
+	default:
+		cmd = []string{"npm", "run", script}
+	}
+
+	if len(args) > 0 {
+		cmd = append(cmd, "--")
+		cmd = append(cmd, args...)
+	}
+	return cmd
+}
+
+// ExecCommand returns the command that runs a package binary, like npx.
+func (pm PackageManager) ExecCommand(bin string, args ...string) []string {
+	var cmd []string
+	switch pm {
+	case PNPM:
+		cmd = []string{"pnpm", "exec", bin}
+	case Yarn:
+		cmd = []string{"yarn", bin}
+	case Bun:
+		cmd = []string{"bunx", bin}
+	default:
+		cmd = []string{"npx", bin}
+	}
+	return append(cmd, args...)
+}
+
+// GlobalInstallCommand returns the command that installs a package globally.
+func (pm PackageManager) GlobalInstallCommand(pkg string) []string {
+	switch pm {
+	case PNPM:
+		return []string{"pnpm", "add", "-g", pkg}
+	case Yarn:
+		return []string{"yarn", "global", "add", pkg}
+	case Bun:
+		return []string{"bun", "add", "-g", pkg}
+	default:
+		return []string{"npm", "install", "-g", pkg}
+	}
+}
@@ -1,112 +1,35 @@
 package exec
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
-)
-
-// DetectFramework detects the test framework (jest or vitest) from package.json and lockfiles.
-func DetectFramework(root string) (string, error) {
-	pkgPath := filepath.Join(root, "package.json")
-	content, err := os.ReadFile(pkgPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read package.json: %w", err)
-	}
-
-	var pkg map[string]interface{}
-	if err := json.Unmarshal(content, &pkg); err != nil {
-		return "", fmt.Errorf("failed to parse package.json: %w", err)
-	}
-
-	// Check devDependencies
-	devDeps, ok := pkg["devDependencies"].(map[string]interface{})
-	if !ok {
-		devDeps = make(map[string]interface{})
-	}
-
-	deps, ok := pkg["dependencies"].(map[string]interface{})
-	if !ok {
-		deps = make(map[string]interface{})
-	}
-
-	hasVitest := false
-	hasJest := false
-
-	if _, ok := devDeps["vitest"]; ok {
-		hasVitest = true
-	}
-	if _, ok := devDeps["jest"]; ok {
-		hasJest = true
-	}
-	if _, ok := deps["vitest"]; ok {
-		hasVitest = true
-	}
-	if _, ok := deps["jest"]; ok {
-		hasJest = true
-	}
-
-	// Prefer vitest if both are present
-	if hasVitest {
-		return "vitest", nil
-	}
-	if hasJest {
-		return "jest", nil
-	}
-
-	// Check lockfiles as fallback
-	if _, err := os.Stat(filepath.Join(root, "pnpm-lock.yaml")); err == nil {
-		// pnpm lockfile exists; check for vitest/jest in it
-		if hasFrameworkInLockfile(root, "vitest") {
-			return "vitest", nil
-		}
-		if hasFrameworkInLockfile(root, "jest") {
-			return "jest", nil
-		}
-	}
-
-	return "", fmt.Errorf("no test framework detected (jest or vitest required)")
-}
+	"time"
 
-// hasFrameworkInLockfile checks if a framework is mentioned in lockfiles.
-func hasFrameworkInLockfile(root string, framework string) bool {
-	lockfiles := []string{
-		filepath.Join(root, "pnpm-lock.yaml"),
-		filepath.Join(root, "yarn.lock"),
-		filepath.Join(root, "package-lock.json"),
-	}
+	"github.com/tanerincode/auto-test-generator/internal/framework"
+)
 
-	for _, lockfile := range lockfiles {
-		content, err := os.ReadFile(lockfile)
-		if err != nil {
-			continue
-		}
-		if strings.Contains(string(content), framework) {
-			return true
+// RunTests runs the test script of the package at root on the specified test files, or on the
+// whole suite if there are none. Packages without a test script run the framework's own
+// command instead.
+func RunTests(ctx context.Context, testPaths []string, fw framework.Framework, root string, pm PackageManager) error {
+	// Test paths are passed relative to the package so its own test script resolves them
+	var args []string
+	for _, testPath := range testPaths {
+		if rel, err := filepath.Rel(root, testPath); err == nil {
+			testPath = rel
 		}
+		args = append(args, testPath)
 	}
 
-	return false
-}
-
-// RunTests runs the test suite on the specified test files.
-func RunTests(testPaths []string, framework string, root string) error {
-	if len(testPaths) == 0 {
-		return nil
-	}
-
-	// Determine the test command
-	var cmd *exec.Cmd
-	if framework == "vitest" {
-		cmd = exec.Command("npm", "run", "test", "--", testPaths[0])
-	} else {
-		cmd = exec.Command("npm", "run", "test", "--", testPaths[0])
-	}
-
+	cmd := command(ctx, testCommand(root, fw, pm, args))
 	cmd.Dir = root
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -119,17 +42,18 @@ func RunTests(testPaths []string, framework string, root string) error {
 }
 
 // GetCoverage retrieves the test coverage percentage.
-func GetCoverage(root string, framework string) (float64, error) {
+func GetCoverage(ctx context.Context, root string, fw framework.Framework, pm PackageManager) (float64, error) {
 	// Try to run coverage command
 	var cmd *exec.Cmd
 
 	// Check if test:coverage script exists
 	if hasScript(root, "test:coverage") {
-		cmd = exec.Command("npm", "run", "test:coverage")
-	} else if framework == "vitest" {
-		cmd = exec.Command("npm", "run", "test", "--", "--coverage")
+		cmd = command(ctx, pm.ScriptCommand("test:coverage"))
+	} else if len(fw.CoverageArgs) > 0 {
+		cmd = command(ctx, testCommand(root, fw, pm, fw.CoverageArgs))
 	} else {
-		cmd = exec.Command("npm", "run", "test", "--", "--coverage")
+		// Frameworks without built-in coverage are measured by wrapping the run in c8
+		cmd = command(ctx, pm.ExecCommand("c8", testCommand(root, fw, pm, nil)...))
 	}
 
 	cmd.Dir = root
@@ -139,8 +63,32 @@ func GetCoverage(root string, framework string) (float64, error) {
 	}
 
 	// Parse coverage from output (simple heuristic)
-	coverage := parseCoverageFromOutput(string(output))
-	return coverage, nil
+	return parseCoverageFromOutput(string(output))
+}
+
+// testCommand returns the command line running the package's tests with extra arguments.
+func testCommand(root string, fw framework.Framework, pm PackageManager, args []string) []string {
+	if hasScript(root, "test") {
+		return pm.ScriptCommand("test", args...)
+	}
+
+	argv := append(append([]string{}, fw.Command...), args...)
+	if fw.Runtime {
+		return argv
+	}
+	return pm.ExecCommand(argv[0], argv[1:]...)
+}
+
+// waitDelay bounds how long a canceled command may keep its output open, e.g. through test
+// workers the package manager started.
+const waitDelay = 5 * time.Second
+
+// command builds an exec.Cmd from a command line such as one returned by PackageManager.
+// The command is killed when ctx is done.
+func command(ctx context.Context, argv []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.WaitDelay = waitDelay
+	return cmd
 }
 
 // hasScript checks if a script exists in package.json.
@@ -165,8 +113,19 @@ func hasScript(root string, scriptName string) bool {
 	return exists
 }
 
-// parseCoverageFromOutput extracts coverage percentage from test output.
-func parseCoverageFromOutput(output string) float64 {
+// allFilesPattern matches the summary row of Istanbul/c8 text tables, "All files |   85.5 | ...",
+// and of node:test's coverage report, "# all files | 85.50 | ...".
+var allFilesPattern = regexp.MustCompile(`(?i)all files\s*\|\s*([\d.]+)`)
+
+// parseCoverageFromOutput extracts coverage percentage from test output, or returns an error if
+// the output has no coverage summary.
+func parseCoverageFromOutput(output string) (float64, error) {
+	if match := allFilesPattern.FindStringSubmatch(output); match != nil {
+		if val, err := strconv.ParseFloat(match[1], 64); err == nil {
+			return val, nil
+		}
+	}
+
 	// Look for patterns like "Coverage: 85.5%" or "Statements: 92.3%" or "85.5% coverage"
 	patterns := []string{
 		"Coverage: ",
@@ -198,11 +157,11 @@ func parseCoverageFromOutput(output string) float64 {
 
 			if numStr != "" {
 				if val, err := strconv.ParseFloat(numStr, 64); err == nil {
-					return val
+					return val, nil
 				}
 			}
 		}
 	}
 
-	return 0
+	return 0, errors.New("no coverage summary found in the test output")
 }
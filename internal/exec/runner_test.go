@@ -0,0 +1,56 @@
+package exec
+
+import "testing"
+
+// nodeTestOutput is the end of the output of node --test --experimental-test-coverage.
+const nodeTestOutput = `# pass 1
+# fail 0
+# duration_ms 154.350078
+# start of coverage report
+# ----------------------------------------------------------
+# file      | line % | branch % | funcs % | uncovered lines
+# ----------------------------------------------------------
+# m.js      | 100.00 |    66.67 |  100.00 | 
+# m.test.js |  92.31 |   100.00 |  100.00 | 3
+# ----------------------------------------------------------
+# all files |  95.45 |    80.00 |  100.00 |
+# ----------------------------------------------------------
+# end of coverage report
+`
+
+// istanbulOutput is the text summary of Jest, Vitest and c8.
+const istanbulOutput = `----------|---------|----------|---------|---------|-------------------
+File      | % Stmts | % Branch | % Funcs | % Lines | Uncovered Line #s
+----------|---------|----------|---------|---------|-------------------
+All files |    85.5 |       75 |     100 |    85.5 |
+ m.ts     |    85.5 |       75 |     100 |    85.5 | 4
+----------|---------|----------|---------|---------|-------------------
+`
+
+func TestParseCoverageFromOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   float64
+	}{
+		{"node:test", nodeTestOutput, 95.45},
+		{"istanbul", istanbulOutput, 85.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCoverageFromOutput(tt.output)
+			if err != nil {
+				t.Fatalf("parseCoverageFromOutput: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("coverage = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCoverageFromOutputWithoutSummary(t *testing.T) {
+	if got, err := parseCoverageFromOutput("# pass 1\n# fail 0\n"); err == nil {
+		t.Errorf("parseCoverageFromOutput = %v, want an error", got)
+	}
+}
@@ -0,0 +1,201 @@
+package framework
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Framework describes how tests are detected, named, written and run for one test runner.
+type Framework struct {
+	// Name is the identifier used by -fw, e.g. "vitest" or "node:test".
+	Name string
+	// Description is a human-readable name used in prompts and messages.
+	Description string
+	// TestSuffix is the test file infix: "test" for foo.test.ts, "spec" for foo.spec.ts.
+	TestSuffix string
+
+	// Packages are dependencies whose presence signals this framework.
+	Packages []string
+	// ScriptMarkers are commands in a "test" script that signal this framework.
+	ScriptMarkers []string
+	// ConfigFiles are glob patterns of config files, relative to the package root.
+	ConfigFiles []string
+	// ImportSources are modules only test files of this framework import.
+	ImportSources []string
+
+	// Imports are the import lines a generated test file starts with.
+	Imports string
+	// Assert is the assertion style generated tests use.
+	Assert AssertionStyle
+
+	// Command runs test files directly when the package has no test script.
+	Command []string
+	// Runtime marks Command as a runtime (node, bun) rather than a package binary.
+	Runtime bool
+	// CoverageArgs are appended to the test script to collect coverage.
+	// If empty, the test script is wrapped in c8 instead.
+	CoverageArgs []string
+}
+
+// AssertionStyle is the assertion library generated tests are written against.
+type AssertionStyle int
+
+const (
+	// Expect is the Jest-style expect(x).toBe(y) API (Jest, Vitest, Bun, Jasmine).
+	Expect AssertionStyle = iota
+	// Chai is Chai's BDD expect(x).to.equal(y) API.
+	Chai
+	// NodeAssert is node:assert/strict.
+	NodeAssert
+)
+
+// Defined returns a statement asserting that expr is not undefined.
+func (a AssertionStyle) Defined(expr string) string {
+	switch a {
+	case Chai:
+		return "expect(" + expr + ").to.not.be.undefined;"
+	case NodeAssert:
+		return "assert.notStrictEqual(" + expr + ", undefined);"
+	default:
+		return "expect(" + expr + ").toBeDefined();"
+	}
+}
+
+// Equal returns a statement asserting that actual strictly equals expected.
+func (a AssertionStyle) Equal(actual string, expected string) string {
+	switch a {
+	case Chai:
+		return "expect(" + actual + ").to.equal(" + expected + ");"
+	case NodeAssert:
+		return "assert.strictEqual(" + actual + ", " + expected + ");"
+	default:
+		return "expect(" + actual + ").toBe(" + expected + ");"
+	}
+}
+
+// String describes the assertion style for prompts.
+func (a AssertionStyle) String() string {
+	switch a {
+	case Chai:
+		return "Chai expect (expect(x).to.equal(y))"
+	case NodeAssert:
+		return "node:assert/strict (assert.strictEqual(x, y))"
+	default:
+		return "expect (expect(x).toBe(y))"
+	}
+}
+
+var registry = []Framework{
+	{
+		Name:          "vitest",
+		Description:   "Vitest",
+		TestSuffix:    "spec",
+		Packages:      []string{"vitest"},
+		ScriptMarkers: []string{"vitest"},
+		ConfigFiles:   []string{"vitest.config.*", "vitest.workspace.*"},
+		ImportSources: []string{"vitest"},
+		Imports:       "import { describe, it, expect, beforeEach, afterEach, vi } from 'vitest';",
+		Assert:        Expect,
+		Command:       []string{"vitest", "run"},
+		CoverageArgs:  []string{"--coverage"},
+	},
+	{
+		Name:          "jest",
+		Description:   "Jest",
+		TestSuffix:    "test",
+		Packages:      []string{"jest"},
+		ScriptMarkers: []string{"jest"},
+		ConfigFiles:   []string{"jest.config.*"},
+		ImportSources: []string{"@jest/globals"},
+		Imports:       "import { describe, it, expect, beforeEach, afterEach, jest } from '@jest/globals';",
+		Assert:        Expect,
+		Command:       []string{"jest"},
+		CoverageArgs:  []string{"--coverage"},
+	},
+	{
+		Name:          "mocha",
+		Description:   "Mocha with Chai",
+		TestSuffix:    "spec",
+		Packages:      []string{"mocha"},
+		ScriptMarkers: []string{"mocha"},
+		ConfigFiles:   []string{".mocharc*"},
+		ImportSources: []string{"mocha"},
+		Imports:       "import { expect } from 'chai';",
+		Assert:        Chai,
+		Command:       []string{"mocha"},
+	},
+	{
+		Name:          "jasmine",
+		Description:   "Jasmine",
+		TestSuffix:    "spec",
+		Packages:      []string{"jasmine"},
+		ScriptMarkers: []string{"jasmine"},
+		ConfigFiles:   []string{"jasmine.json", "spec/support/jasmine.json"},
+		Assert:        Expect,
+		Command:       []string{"jasmine"},
+	},
+	{
+		Name:          "bun",
+		Description:   "Bun test",
+		TestSuffix:    "test",
+		Packages:      []string{"bun-types", "@types/bun"},
+		ScriptMarkers: []string{"bun test"},
+		ImportSources: []string{"bun:test"},
+		Imports:       "import { describe, it, expect, beforeEach, afterEach, mock } from 'bun:test';",
+		Assert:        Expect,
+		Command:       []string{"bun", "test"},
+		Runtime:       true,
+		CoverageArgs:  []string{"--coverage"},
+	},
+	{
+		Name:          "node:test",
+		Description:   "node:test",
+		TestSuffix:    "test",
+		ScriptMarkers: []string{"node --test", "tsx --test"},
+		ImportSources: []string{"node:test"},
+		Imports:       "import { describe, it, beforeEach, afterEach } from 'node:test';\nimport assert from 'node:assert/strict';",
+		Assert:        NodeAssert,
+		Command:       []string{"node", "--test"},
+		Runtime:       true,
+		CoverageArgs:  []string{"--experimental-test-coverage"},
+	},
+}
+
+// aliases maps alternative -fw spellings to framework names.
+var aliases = map[string]string{
+	"node":     "node:test",
+	"bun:test": "bun",
+	"chai":     "mocha",
+}
+
+// All returns every supported framework.
+func All() []Framework {
+	return append([]Framework(nil), registry...)
+}
+
+// Names returns the sorted names of all supported frameworks.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for _, fw := range registry {
+		names = append(names, fw.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the framework with the given name or alias.
+func Lookup(name string) (Framework, error) {
+	name = strings.ToLower(name)
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+
+	for _, fw := range registry {
+		if fw.Name == name {
+			return fw, nil
+		}
+	}
+
+	return Framework{}, fmt.Errorf("unknown framework: %s (must be one of %s)", name, strings.Join(Names(), ", "))
+}
@@ -2,12 +2,17 @@ package gen
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
+	"time"
+
+	runner "github.com/tanerincode/auto-test-generator/internal/exec"
+	"github.com/tanerincode/auto-test-generator/internal/framework"
 )
 
 // AugmentCodeAnalysis represents the analysis result from Augment CLI
@@ -218,168 +223,212 @@ func generateSampleValue(typeStr string) interface{} {
 }
 
 // LoginToAuggie handles user login to Augment Code
-func LoginToAuggie() error {
+func LoginToAuggie(logger *slog.Logger, pm runner.PackageManager) error {
 	// Ensure Auggie is installed first
-	if err := EnsureAuggieCLIInstalled(); err != nil {
+	if err := EnsureAuggieCLIInstalled(logger, pm); err != nil {
 		return err
 	}
 
-	fmt.Println("\n🔐 Logging in to Augment Code...")
-	fmt.Println("Opening browser for authentication...")
+	logger.Info("logging in to Augment Code; opening browser for authentication")
 
 	// Call auggie with a simple instruction to trigger authentication
 	// Auggie will automatically prompt for login if not authenticated
 	cmd := exec.Command("auggie", "Hello, I'm ready to generate tests")
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = os.Stderr
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("login failed: %v", err)
 	}
 
-	fmt.Println("\n✓ Successfully logged in to Augment Code!")
-	fmt.Println("\nYou can now generate tests with:")
-	fmt.Println("  ./autotest -root <project-path> -allow-dirty")
-	fmt.Println("\nExample:")
-	fmt.Println("  ./autotest -root ./my-project -allow-dirty")
-	fmt.Println("  ./autotest -root ./my-project -allow-dirty -dry-run  # Preview first")
+	logger.Info("logged in to Augment Code; generate tests with: autotest generate -root <project-path>")
 	return nil
 }
 
-// EnsureAuggieCLIInstalled checks if Auggie CLI is installed, and offers to install if not
-func EnsureAuggieCLIInstalled() error {
+// EnsureAuggieCLIInstalled checks if Auggie CLI is installed, and offers to install it with pm if not
+func EnsureAuggieCLIInstalled(logger *slog.Logger, pm runner.PackageManager) error {
 	// Check if auggie is already installed
-	cmd := exec.Command("auggie", "--version")
-	if err := cmd.Run(); err == nil {
-		// Already installed
-		fmt.Println("✓ Auggie CLI is already installed")
+	if version, err := AuggieCLIVersion(); err == nil {
+		logger.Debug("auggie CLI is installed", "version", version)
 		return nil
 	}
 
-	// Auggie CLI not found, ask user if they want to install
-	fmt.Println("❌ Auggie CLI not found")
-	fmt.Println("\nAuggie CLI is required for AI-powered test generation.")
-	fmt.Print("\nWould you like to install Auggie CLI now? (y/n): ")
+	// Auggie CLI not found, ask user if they want to install; the prompt goes to stderr like the logs
+	logger.Warn("auggie CLI not found; it is required for AI-powered test generation")
+	fmt.Fprint(os.Stderr, "Would you like to install Auggie CLI now? (y/n): ")
 
 	var response string
 	fmt.Scanln(&response)
 
 	response = strings.ToLower(strings.TrimSpace(response))
 	if response != "y" && response != "yes" {
-		return fmt.Errorf("auggie CLI is required. Install manually with: npm install -g @augmentcode/auggie")
+		return fmt.Errorf("auggie CLI is required. Install manually with: %s", strings.Join(AuggieInstallCommand(pm), " "))
 	}
 
 	// Attempt to install Auggie CLI
-	return installAuggieCLI()
+	return installAuggieCLI(logger, pm)
+}
+
+// auggiePackage is the npm package providing the auggie binary
+const auggiePackage = "@augmentcode/auggie"
+
+// AuggieCLIVersion returns the version reported by the installed Auggie CLI.
+func AuggieCLIVersion() (string, error) {
+	output, err := exec.Command("auggie", "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("auggie CLI not found: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// AuggieInstallCommand returns the command installing Auggie CLI globally with pm.
+func AuggieInstallCommand(pm runner.PackageManager) []string {
+	return pm.GlobalInstallCommand(auggiePackage)
 }
 
-// installAuggieCLI installs Auggie CLI via npm
-func installAuggieCLI() error {
-	fmt.Println("\n📦 Installing Auggie CLI...")
+// installAuggieCLI installs Auggie CLI globally with the given package manager
+func installAuggieCLI(logger *slog.Logger, pm runner.PackageManager) error {
 
-	// Check if npm is available
-	npmCheck := exec.Command("npm", "--version")
-	if err := npmCheck.Run(); err != nil {
-		return fmt.Errorf("npm is not installed. Please install Node.js and npm first from https://nodejs.org/")
+	// Check if the package manager is available
+	pmCheck := exec.Command(string(pm), "--version")
+	if err := pmCheck.Run(); err != nil {
+		return fmt.Errorf("%s is not installed. Please install Node.js and %s first from https://nodejs.org/", pm, pm)
 	}
 
-	// Try to install with npm
-	installCmd := exec.Command("npm", "install", "-g", "@augmentcode/auggie")
-	installCmd.Stdout = os.Stdout
+	// Try to install with the package manager
+	install := pm.GlobalInstallCommand(auggiePackage)
+	installCmd := exec.Command(install[0], install[1:]...)
+	installCmd.Stdout = os.Stderr
 	installCmd.Stderr = os.Stderr
 
-	fmt.Println("Running: npm install -g @augmentcode/auggie")
-	fmt.Println("This may take a minute...")
+	logger.Info("installing Auggie CLI; this may take a minute", "command", strings.Join(install, " "))
 
 	if err := installCmd.Run(); err != nil {
-		fmt.Println("\n❌ Installation failed")
-		fmt.Println("\nYou can try installing manually:")
-		fmt.Println("  npm install -g @augmentcode/auggie")
-		fmt.Println("\nOr with yarn:")
-		fmt.Println("  yarn global add @augmentcode/auggie")
-		return fmt.Errorf("failed to install Auggie CLI: %v", err)
+		manual := strings.Join(install, " ")
+		if pm != runner.NPM {
+			manual += " (or: " + strings.Join(runner.NPM.GlobalInstallCommand(auggiePackage), " ") + ")"
+		}
+		return fmt.Errorf("failed to install Auggie CLI: %v; install it manually with: %s", err, manual)
 	}
 
-	fmt.Println("\n✓ Auggie CLI installed successfully!")
-	fmt.Println("\nNext step: Login to Augment Code")
-	fmt.Println("Run: ./autotest login")
+	logger.Info("installed Auggie CLI; log in with: autotest login")
 	return nil
 }
 
 // EnsureAuggieCLILoggedIn checks if user is logged in to Auggie, and prompts login if not
-func EnsureAuggieCLILoggedIn() error {
+func EnsureAuggieCLILoggedIn(ctx context.Context, logger *slog.Logger) error {
+	if err := CheckAuggieCLILogin(ctx); err != nil {
+		// If help fails, user might not be logged in
+		logger.Debug("auggie login check failed", "error", err)
+		return fmt.Errorf("auggie CLI not authenticated. Please run 'auggie --login' first")
+	}
+
+	return nil
+}
+
+// CheckAuggieCLILogin checks without prompting whether Auggie CLI runs. It doesn't call the
+// service, so a CLI that isn't logged in can still pass.
+func CheckAuggieCLILogin(ctx context.Context) error {
 	// Try a simple auggie command to check if logged in
-	cmd := exec.Command("auggie", "--help")
+	cmd := exec.CommandContext(ctx, "auggie", "--help")
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		// If help fails, user might not be logged in
-		fmt.Println("\n⚠️  Auggie CLI requires authentication")
-		fmt.Println("Please login to Augment Code:")
-		fmt.Println("\n  auggie --login")
-		return fmt.Errorf("auggie CLI not authenticated. Please run 'auggie --login' first")
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("auggie CLI not authenticated: %s", msg)
+		}
+		return fmt.Errorf("auggie CLI not authenticated: %w", err)
 	}
-
 	return nil
 }
 
 // GenerateTestWithAugmentCLI generates tests using Auggie CLI with project context
-func GenerateTestWithAugmentCLI(filePath string, code string, framework string, projectContext string) (string, error) {
-	// Ensure Auggie CLI is installed
-	if err := EnsureAuggieCLIInstalled(); err != nil {
-		return "", fmt.Errorf("auggie CLI setup failed: %w", err)
+// A non-nil focus limits the prompt to the given exports of a file that already has tests.
+// The auggie process is killed when ctx is done. Auggie doesn't report token usage, so the
+// returned usage is estimated; a call that failed after sending the prompt counts its prompt.
+func GenerateTestWithAugmentCLI(ctx context.Context, logger *slog.Logger, filePath string, testPath string, code string, fw framework.Framework, projectContext string, focus *Focus) (string, Usage, error) {
+	// Ensure Auggie CLI is installed; installation itself is offered up front by EnsureAuggieCLIInstalled
+	if _, err := exec.LookPath("auggie"); err != nil {
+		return "", Usage{}, fmt.Errorf("auggie CLI setup failed: %w", err)
 	}
 
 	// Ensure user is logged in
-	if err := EnsureAuggieCLILoggedIn(); err != nil {
-		return "", err
+	if err := EnsureAuggieCLILoggedIn(ctx, logger); err != nil {
+		return "", Usage{}, err
 	}
 
 	// Build the prompt for Auggie
-	prompt := buildAugmentPrompt(filePath, code, framework, projectContext)
+	prompt := buildAugmentPrompt(filePath, testPath, code, fw, projectContext, focus)
+	usage := estimatedUsage(prompt, "")
 
-	fmt.Printf("  ⏳ Generating tests for %s with Auggie...\n", filePath)
-	fmt.Println("  💭 Auggie AI is thinking...")
+	logger.Info("generating tests", "file", filePath, "provider", "auggie")
 
 	// Call Auggie CLI with -p (print mode) flag
-	cmd := exec.Command("auggie", "-p", prompt)
+	cmd := exec.CommandContext(ctx, "auggie", "-p", prompt)
+	cmd.WaitDelay = auggieWaitDelay
 
-	// Capture stdout but show stderr in real-time for progress updates
-	var stdout bytes.Buffer
+	// Capture stderr too, so progress of concurrent runs doesn't interleave on the terminal
+	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = &stderr
 
 	err := cmd.Run()
-	output := stdout.Bytes()
+	msg := strings.TrimSpace(stderr.String())
+	if ctx.Err() != nil {
+		return "", usage, fmt.Errorf("auggie CLI stopped: %w", ctx.Err())
+	}
 	if err != nil {
-		fmt.Printf("  ❌ Failed to generate tests for %s: %v\n", filePath, err)
-		return "", fmt.Errorf("auggie CLI failed: %v", err)
+		if msg != "" {
+			return "", usage, classify(fmt.Errorf("auggie CLI failed: %v: %s", err, msg), msg)
+		}
+		return "", usage, classify(fmt.Errorf("auggie CLI failed: %v", err), "")
+	}
+	if msg != "" {
+		logger.Debug("auggie output", "file", filePath, "stderr", msg)
 	}
 
-	testCode := string(output)
+	testCode := stdout.String()
 	if testCode == "" {
-		return "", fmt.Errorf("auggie CLI returned empty output")
+		return "", usage, &ProviderError{Err: fmt.Errorf("auggie CLI returned empty output"), Retryable: true}
 	}
 
-	fmt.Printf("  ✓ Generated tests for %s\n", filePath)
-	return testCode, nil
+	logger.Info("generated tests", "file", filePath, "provider", "auggie")
+	return testCode, estimatedUsage(prompt, testCode), nil
 }
 
+// auggieWaitDelay bounds how long a killed auggie process may keep its output open.
+const auggieWaitDelay = 5 * time.Second
+
+// DefaultAuggieConcurrency is how many auggie processes run at once unless configured otherwise.
+// Each is a full Node.js process.
+const DefaultAuggieConcurrency = 4
+
+// PromptVersion identifies the prompt template. Bump it whenever buildAugmentPrompt changes, so
+// tests generated from an older prompt are not reused from the cache.
+const PromptVersion = "3"
+
 // buildAugmentPrompt creates a detailed prompt for Auggie CLI
-func buildAugmentPrompt(filePath string, code string, framework string, projectContext string) string {
+func buildAugmentPrompt(filePath string, testPath string, code string, fw framework.Framework, projectContext string, focus *Focus) string {
 	var prompt strings.Builder
 
-	prompt.WriteString("Generate comprehensive Jest/Vitest tests for the following TypeScript file:\n\n")
+	prompt.WriteString("Generate comprehensive " + fw.Description + " tests for the following TypeScript file:\n\n")
 	prompt.WriteString("## File: " + filePath + "\n\n")
+	prompt.WriteString("## Test File: " + testPath + "\n\n")
+	prompt.WriteString("The tests will be written to the test file above, so import the code under test from '" + ImportPath(testPath, filePath) + "'.\n\n")
 
 	prompt.WriteString("## Source Code:\n")
 	prompt.WriteString("```typescript\n")
 	prompt.WriteString(code)
 	prompt.WriteString("\n```\n\n")
 
-	prompt.WriteString("## Test Framework: " + framework + "\n\n")
+	prompt.WriteString("## Test Framework: " + fw.Description + "\n\n")
+	if fw.Imports != "" {
+		prompt.WriteString("Import the test API with:\n")
+		prompt.WriteString("```typescript\n" + fw.Imports + "\n```\n\n")
+	}
+	prompt.WriteString("Assertion style: " + fw.Assert.String() + "\n\n")
 
 	if projectContext != "" {
 		prompt.WriteString("## Project Context:\n")
@@ -387,6 +436,30 @@ func buildAugmentPrompt(filePath string, code string, framework string, projectC
 		prompt.WriteString("\n\n")
 	}
 
+	if focus != nil {
+		prompt.WriteString("## Existing Tests (" + focus.TestPath + "):\n")
+		prompt.WriteString("```typescript\n")
+		prompt.WriteString(focus.Existing)
+		prompt.WriteString("\n```\n\n")
+		if untested := focus.untested(); len(untested) > 0 {
+			prompt.WriteString("Only these exports have no tests yet: " + strings.Join(untested, ", ") + "\n")
+		}
+		if len(focus.Changed) > 0 {
+			prompt.WriteString("These exports changed since the existing tests were written: " + strings.Join(focus.Changed, ", ") + "\n")
+			if methods := changedMethods(code, focus.Changed, focus.Lines); len(methods) > 0 {
+				prompt.WriteString("The changed methods are: " + strings.Join(methods, ", ") + "\n")
+			}
+			lines := make([]string, len(focus.Lines))
+			for i, r := range focus.Lines {
+				lines[i] = r.String()
+			}
+			prompt.WriteString("The changed lines are: " + strings.Join(lines, ", ") + "\n")
+			prompt.WriteString("The existing top-level describe blocks of these exports will be replaced by the ones you generate, so cover their full current behavior, including the changes.\n")
+		}
+		prompt.WriteString("Generate one top-level describe block per export listed above, named after it, plus the imports those blocks need.\n")
+		prompt.WriteString("The other existing tests will be kept as they are; do not repeat them.\n\n")
+	}
+
 	prompt.WriteString("## Requirements:\n")
 	prompt.WriteString("1. Generate comprehensive test cases covering:\n")
 	prompt.WriteString("   - Happy path scenarios\n")
@@ -401,8 +474,13 @@ func buildAugmentPrompt(filePath string, code string, framework string, projectC
 	return prompt.String()
 }
 
+// EstimatePromptTokens estimates the number of tokens in the prompt sent for a file.
+func EstimatePromptTokens(filePath string, testPath string, code string, fw framework.Framework, projectContext string, focus *Focus) int {
+	return EstimateTokens(buildAugmentPrompt(filePath, testPath, code, fw, projectContext, focus))
+}
+
 // GenerateTestWithAugment generates a test file using Augment analysis
-func GenerateTestWithAugment(tsPath string, code string, framework string, projectRoot string) (string, error) {
+func GenerateTestWithAugment(tsPath string, testPath string, code string, fw framework.Framework, projectRoot string) (string, error) {
 	// Analyze the code with Augment
 	analysis, err := AnalyzeWithAugment(tsPath, code, projectRoot)
 	if err != nil {
@@ -414,12 +492,12 @@ func GenerateTestWithAugment(tsPath string, code string, framework string, proje
 	}
 
 	// Generate test code based on analysis
-	testCode := generateTestCodeFromAnalysis(tsPath, analysis, framework)
+	testCode := generateTestCodeFromAnalysis(tsPath, testPath, analysis, fw)
 	return testCode, nil
 }
 
 // generateTestCodeFromAnalysis creates test code from Augment analysis
-func generateTestCodeFromAnalysis(tsPath string, analysis *AugmentCodeAnalysis, framework string) string {
+func generateTestCodeFromAnalysis(tsPath string, testPath string, analysis *AugmentCodeAnalysis, fw framework.Framework) string {
 	var sb strings.Builder
 
 	// Header
@@ -430,8 +508,6 @@ func generateTestCodeFromAnalysis(tsPath string, analysis *AugmentCodeAnalysis,
 	sb.WriteString(" */\n\n")
 
 	// Import statement
-	importPath := strings.TrimSuffix(tsPath, filepath.Ext(tsPath))
-	importPath = strings.TrimPrefix(importPath, "./")
 	sb.WriteString("import { ")
 
 	for i, exp := range analysis.Exports {
@@ -440,18 +516,16 @@ func generateTestCodeFromAnalysis(tsPath string, analysis *AugmentCodeAnalysis,
 		}
 		sb.WriteString(exp.Name)
 	}
-	sb.WriteString(" } from '../" + importPath + "';\n\n")
+	sb.WriteString(" } from '" + ImportPath(testPath, tsPath) + "';\n\n")
 
 	// Test framework setup
-	if framework == "vitest" {
-		sb.WriteString("import { describe, it, expect, beforeEach, afterEach, vi } from 'vitest';\n\n")
-	} else {
-		sb.WriteString("import { describe, it, expect, beforeEach, afterEach, jest } from '@jest/globals';\n\n")
+	if fw.Imports != "" {
+		sb.WriteString(fw.Imports + "\n\n")
 	}
 
 	// Generate tests for each export
 	for _, exp := range analysis.Exports {
-		sb.WriteString(generateTestsForExport(exp, analysis.TestScenarios, framework))
+		sb.WriteString(generateTestsForExport(exp, analysis.TestScenarios, fw.Assert))
 		sb.WriteString("\n")
 	}
 
@@ -459,7 +533,7 @@ func generateTestCodeFromAnalysis(tsPath string, analysis *AugmentCodeAnalysis,
 }
 
 // generateTestsForExport generates test cases for a single export
-func generateTestsForExport(exp ExportedFunction, scenarios []TestScenario, framework string) string {
+func generateTestsForExport(exp ExportedFunction, scenarios []TestScenario, assert framework.AssertionStyle) string {
 	var sb strings.Builder
 
 	sb.WriteString("describe('" + exp.Name + "', () => {\n")
@@ -470,19 +544,19 @@ func generateTestsForExport(exp ExportedFunction, scenarios []TestScenario, fram
 	// Generate tests for each scenario
 	for _, scenario := range scenarios {
 		if strings.Contains(scenario.Name, exp.Name) {
-			sb.WriteString(generateTestCase(exp, scenario, framework))
+			sb.WriteString(generateTestCase(exp, scenario, assert))
 			sb.WriteString("\n")
 		}
 	}
 
 	// Add a basic existence test
 	sb.WriteString("  it('should be defined', () => {\n")
-	sb.WriteString("    expect(" + exp.Name + ").toBeDefined();\n")
+	sb.WriteString("    " + assert.Defined(exp.Name) + "\n")
 	sb.WriteString("  });\n\n")
 
 	// Add type check
 	sb.WriteString("  it('should be a " + exp.Type + "', () => {\n")
-	sb.WriteString("    expect(typeof " + exp.Name + ").toBe('" + getTypeofValue(exp.Type) + "');\n")
+	sb.WriteString("    " + assert.Equal("typeof "+exp.Name, "'"+getTypeofValue(exp.Type)+"'") + "\n")
 	sb.WriteString("  });\n")
 
 	sb.WriteString("});\n")
@@ -490,7 +564,7 @@ func generateTestsForExport(exp ExportedFunction, scenarios []TestScenario, fram
 }
 
 // generateTestCase generates a single test case
-func generateTestCase(exp ExportedFunction, scenario TestScenario, framework string) string {
+func generateTestCase(exp ExportedFunction, scenario TestScenario, assert framework.AssertionStyle) string {
 	var sb strings.Builder
 
 	testName := scenario.Name
@@ -534,7 +608,7 @@ func generateTestCase(exp ExportedFunction, scenario TestScenario, framework str
 
 	// Assert
 	sb.WriteString("    // Assert\n")
-	sb.WriteString("    expect(result).toBeDefined();\n")
+	sb.WriteString("    " + assert.Defined("result") + "\n")
 	sb.WriteString("    // TODO: Add specific assertions based on expected behavior\n")
 
 	sb.WriteString("  });\n")
@@ -2,6 +2,8 @@ package gen
 
 import (
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,22 +16,24 @@ type AugmentContextEngine struct {
 	Exports      map[string][]ExportedFunction
 	Dependencies map[string][]string
 	Initialized  bool
+	Logger       *slog.Logger
 }
 
 // NewAugmentContextEngine creates a new context engine for the project
-func NewAugmentContextEngine(projectRoot string) *AugmentContextEngine {
+func NewAugmentContextEngine(projectRoot string, logger *slog.Logger) *AugmentContextEngine {
 	return &AugmentContextEngine{
 		ProjectRoot:  projectRoot,
 		IndexedCode:  make(map[string]string),
 		Exports:      make(map[string][]ExportedFunction),
 		Dependencies: make(map[string][]string),
 		Initialized:  false,
+		Logger:       logger,
 	}
 }
 
 // IndexProject scans and indexes all TypeScript files in the project
 func (ace *AugmentContextEngine) IndexProject() error {
-	fmt.Println("🔍 Indexing project with Augment context engine...")
+	ace.Logger.Info("indexing project with Augment context engine", "root", ace.ProjectRoot)
 
 	// Find all TypeScript files
 	err := filepath.Walk(ace.ProjectRoot, func(path string, info os.FileInfo, err error) error {
@@ -67,7 +71,7 @@ func (ace *AugmentContextEngine) IndexProject() error {
 		// Read file content
 		content, err := os.ReadFile(path)
 		if err != nil {
-			fmt.Printf("⚠️  Failed to read %s: %v\n", path, err)
+			ace.Logger.Warn("failed to read file", "file", path, "error", err)
 			return nil
 		}
 
@@ -99,7 +103,7 @@ func (ace *AugmentContextEngine) IndexProject() error {
 	}
 
 	ace.Initialized = true
-	fmt.Printf("✓ Indexed %d TypeScript files\n", len(ace.IndexedCode))
+	ace.Logger.Info("indexed project", "files", len(ace.IndexedCode))
 	return nil
 }
 
@@ -304,19 +308,19 @@ func (ace *AugmentContextEngine) GetFileContext(targetFile string) map[string]in
 	return context
 }
 
-// PrintIndexSummary prints a summary of the indexed project
-func (ace *AugmentContextEngine) PrintIndexSummary() {
+// PrintIndexSummary prints a summary of the indexed project to w
+func (ace *AugmentContextEngine) PrintIndexSummary(w io.Writer) {
 	if !ace.Initialized {
-		fmt.Println("Project not indexed yet")
+		fmt.Fprintln(w, "Project not indexed yet")
 		return
 	}
 
 	stats := ace.GetProjectStats()
-	fmt.Println("\n📊 Project Index Summary")
-	fmt.Println("========================")
-	fmt.Printf("Files indexed: %d\n", stats["files_indexed"])
-	fmt.Printf("Total exports: %d\n", stats["total_exports"])
-	fmt.Printf("Total dependencies: %d\n", stats["total_dependencies"])
-	fmt.Printf("Avg exports per file: %.2f\n", stats["avg_exports_per_file"])
-	fmt.Println()
+	fmt.Fprintln(w, "\n📊 Project Index Summary")
+	fmt.Fprintln(w, "========================")
+	fmt.Fprintf(w, "Files indexed: %d\n", stats["files_indexed"])
+	fmt.Fprintf(w, "Total exports: %d\n", stats["total_exports"])
+	fmt.Fprintf(w, "Total dependencies: %d\n", stats["total_dependencies"])
+	fmt.Fprintf(w, "Avg exports per file: %.2f\n", stats["avg_exports_per_file"])
+	fmt.Fprintln(w)
 }
@@ -2,8 +2,12 @@ package gen
 
 import (
 	"fmt"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/tanerincode/auto-test-generator/internal/framework"
 )
 
 // TestResult holds the result of test generation for a single file.
@@ -12,34 +16,68 @@ type TestResult struct {
 	TestPath   string
 	TestCode   string
 	Error      error
+	// SourceHash is the hash of the source content the tests were generated from.
+	SourceHash string
+	// Augmented lists the exports whose tests were merged into an existing test file.
+	Augmented []string
+	// Provider is the provider that generated the tests.
+	Provider string
+	// Attempts is the number of provider calls made.
+	Attempts int
+	// Cached is set when the tests were reused from the cache instead of generated.
+	Cached bool
+	// Duration is the time spent generating and merging the tests.
+	Duration time.Duration
+	// Usage is the token usage of all provider calls made.
+	Usage Usage
+	// Cost is the price of Usage in US dollars, counting only priced models.
+	Cost float64
 }
 
-// GenerateTest generates a test file for the given TypeScript source code.
+// GenerateTest generates a test file for the given TypeScript source code, to be written at testPath.
 // Uses basic regex-based analysis.
-func GenerateTest(tsPath string, code string, framework string) (string, error) {
+func GenerateTest(tsPath string, testPath string, code string, fw framework.Framework) (string, error) {
+	return generateBasicTest(tsPath, testPath, code, fw, nil)
+}
+
+// GenerateOfflineTest generates tests without any provider, like GenerateTest, limited to the
+// focused exports if focus is set. It is the last resort of a provider chain.
+func GenerateOfflineTest(tsPath string, testPath string, code string, fw framework.Framework, focus *Focus) (string, error) {
+	return generateBasicTest(tsPath, testPath, code, fw, focus)
+}
+
+// generateBasicTest generates a regex-based test file, limited to the focused exports if focus is set.
+func generateBasicTest(tsPath string, testPath string, code string, fw framework.Framework, focus *Focus) (string, error) {
 	// Extract exported symbols
-	exports := extractExports(code)
+	exports := focus.filter(extractExports(code))
 	if len(exports) == 0 {
 		return "", fmt.Errorf("no exported symbols found in %s", tsPath)
 	}
 
-	// Determine test framework syntax
-	var testSyntax string
-	if framework == "vitest" {
-		testSyntax = "vitest"
-	} else {
-		testSyntax = "jest"
-	}
-
 	// Generate test code
-	testCode := generateTestCode(tsPath, exports, code, testSyntax)
+	testCode := generateTestCode(tsPath, testPath, exports, code, fw)
 	return testCode, nil
 }
 
 // GenerateTestWithContext generates a test file using Augment CLI for code understanding.
 // This provides more intelligent test generation based on actual code analysis.
-func GenerateTestWithContext(tsPath string, code string, framework string, projectRoot string) (string, error) {
-	return GenerateTestWithAugment(tsPath, code, framework, projectRoot)
+func GenerateTestWithContext(tsPath string, testPath string, code string, fw framework.Framework, projectRoot string) (string, error) {
+	return GenerateTestWithAugment(tsPath, testPath, code, fw, projectRoot)
+}
+
+// ImportPath returns the module specifier that imports the source file at sourcePath from the test
+// file at testPath, e.g. "../src/math" or "./math". Both paths are relative to the same directory.
+func ImportPath(testPath string, sourcePath string) string {
+	source := strings.TrimSuffix(sourcePath, filepath.Ext(sourcePath))
+	rel, err := filepath.Rel(filepath.Dir(testPath), source)
+	if err != nil {
+		rel = source
+	}
+	rel = filepath.ToSlash(rel)
+	if !strings.HasPrefix(rel, "../") {
+		rel = "./" + rel
+	}
+	return rel
 }
 
 // exportedSymbol represents an exported function or class.
@@ -51,6 +89,19 @@ type exportedSymbol struct {
 	isDefault bool
 }
 
+// Exports returns the names of the symbols code exports, in order of appearance.
+func Exports(code string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, exp := range extractExports(code) {
+		if !seen[exp.name] {
+			seen[exp.name] = true
+			names = append(names, exp.name)
+		}
+	}
+	return names
+}
+
 // extractExports parses TypeScript code and extracts exported symbols.
 func extractExports(code string) []exportedSymbol {
 	var exports []exportedSymbol
@@ -133,7 +184,7 @@ func parseParams(paramStr string) []string {
 }
 
 // generateTestCode creates the test file content.
-func generateTestCode(tsPath string, exports []exportedSymbol, sourceCode string, testSyntax string) string {
+func generateTestCode(tsPath string, testPath string, exports []exportedSymbol, sourceCode string, fw framework.Framework) string {
 	var sb strings.Builder
 
 	// Header
@@ -143,8 +194,6 @@ func generateTestCode(tsPath string, exports []exportedSymbol, sourceCode string
 	sb.WriteString(" */\n\n")
 
 	// Import statement
-	importPath := strings.TrimSuffix(tsPath, ".ts")
-	importPath = strings.TrimSuffix(importPath, ".tsx")
 	sb.WriteString("import { ")
 
 	for i, exp := range exports {
@@ -153,18 +202,16 @@ func generateTestCode(tsPath string, exports []exportedSymbol, sourceCode string
 		}
 		sb.WriteString(exp.name)
 	}
-	sb.WriteString(" } from '../" + importPath + "';\n\n")
+	sb.WriteString(" } from '" + ImportPath(testPath, tsPath) + "';\n\n")
 
 	// Test framework setup
-	if testSyntax == "vitest" {
-		sb.WriteString("import { describe, it, expect, beforeEach, afterEach } from 'vitest';\n\n")
-	} else {
-		sb.WriteString("import { describe, it, expect, beforeEach, afterEach } from '@jest/globals';\n\n")
+	if fw.Imports != "" {
+		sb.WriteString(fw.Imports + "\n\n")
 	}
 
 	// Generate tests for each export
 	for _, exp := range exports {
-		sb.WriteString(generateTestForSymbol(exp))
+		sb.WriteString(generateTestForSymbol(exp, fw.Assert))
 		sb.WriteString("\n")
 	}
 
@@ -172,18 +219,18 @@ func generateTestCode(tsPath string, exports []exportedSymbol, sourceCode string
 }
 
 // generateTestForSymbol generates test cases for a single exported symbol.
-func generateTestForSymbol(sym exportedSymbol) string {
+func generateTestForSymbol(sym exportedSymbol, assert framework.AssertionStyle) string {
 	var sb strings.Builder
 
 	sb.WriteString("describe('" + sym.name + "', () => {\n")
 
 	switch sym.kind {
 	case "function", "const":
-		sb.WriteString(generateFunctionTests(sym))
+		sb.WriteString(generateFunctionTests(sym, assert))
 	case "class":
-		sb.WriteString(generateClassTests(sym))
+		sb.WriteString(generateClassTests(sym, assert))
 	case "default":
-		sb.WriteString(generateDefaultTests(sym))
+		sb.WriteString(generateDefaultTests(sym, assert))
 	}
 
 	sb.WriteString("});\n")
@@ -191,12 +238,12 @@ func generateTestForSymbol(sym exportedSymbol) string {
 }
 
 // generateFunctionTests generates test cases for a function.
-func generateFunctionTests(sym exportedSymbol) string {
+func generateFunctionTests(sym exportedSymbol, assert framework.AssertionStyle) string {
 	var sb strings.Builder
 
 	// Basic happy path test
 	sb.WriteString("  it('should be defined', () => {\n")
-	sb.WriteString("    expect(" + sym.name + ").toBeDefined();\n")
+	sb.WriteString("    " + assert.Defined(sym.name) + "\n")
 	sb.WriteString("  });\n\n")
 
 	// If function has parameters, add a basic call test
@@ -211,7 +258,7 @@ func generateFunctionTests(sym exportedSymbol) string {
 			sb.WriteString("null")
 		}
 		sb.WriteString(");\n")
-		sb.WriteString("    expect(result).toBeDefined();\n")
+		sb.WriteString("    " + assert.Defined("result") + "\n")
 		sb.WriteString("  });\n\n")
 	}
 
@@ -227,43 +274,43 @@ func generateFunctionTests(sym exportedSymbol) string {
 			sb.WriteString("null")
 		}
 		sb.WriteString(");\n")
-		sb.WriteString("    expect(result).toBeDefined();\n")
+		sb.WriteString("    " + assert.Defined("result") + "\n")
 		sb.WriteString("  });\n\n")
 	}
 
 	// Edge case: null/undefined handling
 	sb.WriteString("  it('should handle edge cases', () => {\n")
 	sb.WriteString("    // TODO: Add edge case tests\n")
-	sb.WriteString("    expect(true).toBe(true);\n")
+	sb.WriteString("    " + assert.Equal("true", "true") + "\n")
 	sb.WriteString("  });\n")
 
 	return sb.String()
 }
 
 // generateClassTests generates test cases for a class.
-func generateClassTests(sym exportedSymbol) string {
+func generateClassTests(sym exportedSymbol, assert framework.AssertionStyle) string {
 	var sb strings.Builder
 
 	sb.WriteString("  it('should be instantiable', () => {\n")
 	sb.WriteString("    const instance = new " + sym.name + "();\n")
-	sb.WriteString("    expect(instance).toBeDefined();\n")
+	sb.WriteString("    " + assert.Defined("instance") + "\n")
 	sb.WriteString("  });\n\n")
 
 	sb.WriteString("  it('should have expected methods', () => {\n")
 	sb.WriteString("    const instance = new " + sym.name + "();\n")
 	sb.WriteString("    // TODO: Add method existence checks\n")
-	sb.WriteString("    expect(instance).toBeDefined();\n")
+	sb.WriteString("    " + assert.Defined("instance") + "\n")
 	sb.WriteString("  });\n")
 
 	return sb.String()
 }
 
 // generateDefaultTests generates test cases for default exports.
-func generateDefaultTests(sym exportedSymbol) string {
+func generateDefaultTests(sym exportedSymbol, assert framework.AssertionStyle) string {
 	var sb strings.Builder
 
 	sb.WriteString("  it('should be defined', () => {\n")
-	sb.WriteString("    expect(" + sym.name + ").toBeDefined();\n")
+	sb.WriteString("    " + assert.Defined(sym.name) + "\n")
 	sb.WriteString("  });\n")
 
 	return sb.String()
@@ -0,0 +1,44 @@
+package gen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tanerincode/auto-test-generator/internal/framework"
+)
+
+func TestImportPath(t *testing.T) {
+	tests := []struct {
+		test   string
+		source string
+		want   string
+	}{
+		{"src/math.spec.ts", "src/math.ts", "./math"},
+		{"src/__tests__/math.spec.ts", "src/math.ts", "../math"},
+		{"test/src/util/math.test.ts", "src/util/math.tsx", "../../../src/util/math"},
+		{"src/math.spec.ts", "src/lib/math.ts", "./lib/math"},
+	}
+	for _, tt := range tests {
+		if got := ImportPath(tt.test, tt.source); got != tt.want {
+			t.Errorf("ImportPath(%q, %q) = %q, want %q", tt.test, tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateOfflineTestImportsFromTestLocation(t *testing.T) {
+	fw := framework.Framework{Assert: framework.Expect}
+	code, err := GenerateOfflineTest("src/math.ts", "src/__tests__/math.test.ts", "export function add(a: number, b: number) { return a + b; }", fw, nil)
+	if err != nil {
+		t.Fatalf("GenerateOfflineTest: %v", err)
+	}
+	if !strings.Contains(code, "import { add } from '../math';") {
+		t.Errorf("test code doesn't import ../math:\n%s", code)
+	}
+}
+
+func TestPromptNamesTestFile(t *testing.T) {
+	prompt := buildAugmentPrompt("src/math.ts", "test/src/math.spec.ts", "export const one = () => 1;", framework.Framework{}, "", nil)
+	if !strings.Contains(prompt, "test/src/math.spec.ts") || !strings.Contains(prompt, "'../../src/math'") {
+		t.Errorf("prompt doesn't give the test file and its import:\n%s", prompt)
+	}
+}
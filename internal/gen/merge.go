@@ -0,0 +1,527 @@
+package gen
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tanerincode/auto-test-generator/internal/diff"
+)
+
+// Focus narrows generation to some exports of a file whose test file already exists.
+type Focus struct {
+	// Exports are the export names to generate tests for.
+	Exports []string
+	// TestPath is the existing test file.
+	TestPath string
+	// Existing is the current content of the test file.
+	Existing string
+	// Changed are the exports among Exports whose code changed since the existing tests were
+	// written; the others have no tests yet.
+	Changed []string
+	// Lines are the changed lines of the source.
+	Lines []diff.Range
+}
+
+// untested returns the exports of the focus that have no tests yet.
+func (f *Focus) untested() []string {
+	var untested []string
+	for _, name := range f.Exports {
+		if !slices.Contains(f.Changed, name) {
+			untested = append(untested, name)
+		}
+	}
+	return untested
+}
+
+// filter keeps only the symbols named in the focus. A nil focus keeps everything.
+func (f *Focus) filter(exports []exportedSymbol) []exportedSymbol {
+	if f == nil {
+		return exports
+	}
+
+	var result []exportedSymbol
+	for _, exp := range exports {
+		for _, name := range f.Exports {
+			if exp.name == name {
+				result = append(result, exp)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// titlePattern matches the title of describe/it/test calls, either a string literal or Symbol.name.
+var titlePattern = regexp.MustCompile(`\b(?:describe|it|test)(?:\.\w+)*\s*\(\s*(?:'((?:\\.|[^'\\])*)'|"((?:\\.|[^"\\])*)"|` + "`((?:\\\\.|[^`\\\\])*)`" + `|(\w+)\.name\b)`)
+
+// titleOf returns the title captured by a titlePattern match.
+func titleOf(match []string) string {
+	for _, group := range match[1:] {
+		if group != "" {
+			return group
+		}
+	}
+	return ""
+}
+
+// UntestedExports returns the exports of code that no describe/it/test title in testCode mentions.
+func UntestedExports(code string, testCode string) []string {
+	var titles []string
+	for _, match := range titlePattern.FindAllStringSubmatch(testCode, -1) {
+		titles = append(titles, titleOf(match))
+	}
+
+	var untested []string
+	seen := make(map[string]bool)
+	for _, exp := range extractExports(code) {
+		if seen[exp.name] {
+			continue
+		}
+		seen[exp.name] = true
+
+		word := regexp.MustCompile(`\b` + regexp.QuoteMeta(exp.name) + `\b`)
+		covered := false
+		for _, title := range titles {
+			if word.MatchString(title) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			untested = append(untested, exp.name)
+		}
+	}
+
+	return untested
+}
+
+// MergeTests merges the top-level describe blocks of generated that cover one of exports into
+// existing, along with any imports they need that existing lacks. A block for one of changed
+// replaces the top-level describe blocks of existing for the same export; the other blocks are
+// added to the end. The rest of existing is left byte-for-byte untouched; new imports go on their
+// own lines after the last one.
+func MergeTests(sourceCode string, existing string, generated string, exports []string, changed []string) (string, error) {
+	generated = stripCodeFence(generated)
+
+	blocks := topLevelCalls(generated, "describe")
+	if len(blocks) == 0 {
+		return "", fmt.Errorf("generated code has no describe blocks to merge")
+	}
+
+	// Keep only the blocks for the requested exports, so covered exports don't get duplicates
+	var selected []string
+	for _, block := range blocks {
+		if mentionsAny(block, exports) {
+			selected = append(selected, block)
+		}
+	}
+	if len(selected) == 0 {
+		return "", fmt.Errorf("generated code has no describe block titled after %s", strings.Join(exports, ", "))
+	}
+
+	// The blocks of changed exports take the place of their outdated ones
+	spans := topLevelCallSpans(existing, "describe")
+	replaced := make(map[int]string) // start of an existing block -> its replacement
+	var added []string
+	for _, block := range selected {
+		var names []string
+		for _, name := range changed {
+			if mentionsAny(block, []string{name}) {
+				names = append(names, name)
+			}
+		}
+
+		first := true
+		for _, span := range spans {
+			if _, done := replaced[span[0]]; done || len(names) == 0 || !mentionsAny(existing[span[0]:span[1]], names) {
+				continue
+			}
+			if first {
+				replaced[span[0]] = block
+				first = false
+			} else {
+				replaced[span[0]] = ""
+			}
+		}
+		if first {
+			added = append(added, block)
+		}
+	}
+
+	merged := existing
+	for i := len(spans) - 1; i >= 0; i-- {
+		block, ok := replaced[spans[i][0]]
+		if !ok {
+			continue
+		}
+		end := spans[i][1]
+		if block == "" {
+			// Drop the blank lines after a removed block too
+			for end < len(merged) && merged[end] == '\n' {
+				end++
+			}
+		}
+		merged = merged[:spans[i][0]] + block + merged[end:]
+	}
+
+	merged = insertImports(sourceCode, merged, parseImports(generated), strings.Join(selected, "\n\n"))
+	if len(added) > 0 {
+		merged = strings.TrimRight(merged, "\n") + "\n\n" + strings.Join(added, "\n\n") + "\n"
+	}
+	return merged, nil
+}
+
+// mentionsAny reports whether the title of the block's outer call mentions one of names.
+func mentionsAny(block string, names []string) bool {
+	match := titlePattern.FindStringSubmatch(block)
+	if match == nil {
+		return false
+	}
+	title := titleOf(match)
+
+	for _, name := range names {
+		if regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`).MatchString(title) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripCodeFence removes a surrounding markdown code fence from provider output.
+func stripCodeFence(code string) string {
+	trimmed := strings.TrimSpace(code)
+	if !strings.HasPrefix(trimmed, "```") {
+		return code
+	}
+
+	lines := strings.Split(trimmed, "\n")
+	lines = lines[1:]
+	if len(lines) > 0 && strings.HasPrefix(strings.TrimSpace(lines[len(lines)-1]), "```") {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// importDecl is a parsed `import ... from '...'` statement.
+type importDecl struct {
+	module    string
+	typeOnly  bool
+	defName   string
+	namespace string
+	named     []string // specifiers as written, e.g. "a" or "b as c"
+	end       int      // offset just past the statement
+}
+
+// importStmtPattern matches import statements with bindings, possibly spanning lines.
+var importStmtPattern = regexp.MustCompile(`(?ms)^import\s+([^'"]+?)\s+from\s+['"]([^'"]+)['"];?`)
+
+// parseImports returns the import statements of code that bind names.
+func parseImports(code string) []importDecl {
+	var decls []importDecl
+	for _, loc := range importStmtPattern.FindAllStringSubmatchIndex(code, -1) {
+		clause := code[loc[2]:loc[3]]
+		decl := importDecl{module: code[loc[4]:loc[5]], end: loc[1]}
+
+		if strings.HasPrefix(clause, "type ") {
+			decl.typeOnly = true
+			clause = strings.TrimSpace(strings.TrimPrefix(clause, "type "))
+		}
+
+		if open := strings.Index(clause, "{"); open != -1 {
+			close := strings.LastIndex(clause, "}")
+			if close > open {
+				for _, spec := range strings.Split(clause[open+1:close], ",") {
+					if spec = strings.Join(strings.Fields(spec), " "); spec != "" {
+						decl.named = append(decl.named, spec)
+					}
+				}
+			}
+			clause = clause[:open]
+		}
+
+		for _, part := range strings.Split(clause, ",") {
+			part = strings.TrimSpace(part)
+			switch {
+			case strings.HasPrefix(part, "*"):
+				decl.namespace = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(part, "*")), "as"))
+			case part != "":
+				decl.defName = part
+			}
+		}
+
+		decls = append(decls, decl)
+	}
+	return decls
+}
+
+// localName returns the binding a named import specifier introduces.
+func localName(spec string) string {
+	spec = strings.TrimPrefix(spec, "type ")
+	if _, local, ok := strings.Cut(spec, " as "); ok {
+		return strings.TrimSpace(local)
+	}
+	return strings.TrimSpace(spec)
+}
+
+// importedName returns the exported name a named import specifier refers to.
+func importedName(spec string) string {
+	imported, _, _ := strings.Cut(strings.TrimPrefix(spec, "type "), " as ")
+	return strings.TrimSpace(imported)
+}
+
+// insertImports adds the bindings of wanted that body uses and existing does not bind yet.
+// Imports of the source module are rewritten to the specifier existing already uses for it.
+func insertImports(sourceCode string, existing string, wanted []importDecl, body string) string {
+	current := parseImports(existing)
+
+	// Names body doesn't use count as bound, so no import is added for them
+	bound := make(map[string]bool)
+	for _, decl := range wanted {
+		names := append([]string{decl.defName, decl.namespace}, decl.named...)
+		for _, name := range names {
+			if name = localName(name); name != "" && !regexp.MustCompile(`\b`+regexp.QuoteMeta(name)+`\b`).MatchString(body) {
+				bound[name] = true
+			}
+		}
+	}
+	for _, decl := range current {
+		bound[decl.defName] = true
+		bound[decl.namespace] = true
+		for _, spec := range decl.named {
+			bound[localName(spec)] = true
+		}
+	}
+
+	sourceExports := make(map[string]bool)
+	for _, exp := range extractExports(sourceCode) {
+		sourceExports[exp.name] = true
+	}
+	sourceModule := ""
+	for _, decl := range current {
+		for _, spec := range decl.named {
+			if sourceExports[importedName(spec)] {
+				sourceModule = decl.module
+			}
+		}
+	}
+
+	var lines []string
+	for _, decl := range wanted {
+		module := decl.module
+		if sourceModule != "" && len(decl.named) > 0 && sourceExports[importedName(decl.named[0])] {
+			module = sourceModule
+		}
+
+		keyword := "import "
+		if decl.typeOnly {
+			keyword = "import type "
+		}
+
+		if decl.defName != "" && !bound[decl.defName] {
+			lines = append(lines, fmt.Sprintf("%s%s from '%s';", keyword, decl.defName, module))
+			bound[decl.defName] = true
+		}
+		if decl.namespace != "" && !bound[decl.namespace] {
+			lines = append(lines, fmt.Sprintf("%s* as %s from '%s';", keyword, decl.namespace, module))
+			bound[decl.namespace] = true
+		}
+
+		var missing []string
+		for _, spec := range decl.named {
+			if name := localName(spec); !bound[name] {
+				missing = append(missing, spec)
+				bound[name] = true
+			}
+		}
+		if len(missing) > 0 {
+			lines = append(lines, fmt.Sprintf("%s{ %s } from '%s';", keyword, strings.Join(missing, ", "), module))
+		}
+	}
+
+	if len(lines) == 0 {
+		return existing
+	}
+
+	// Insert after the line holding the end of the last import, or at the top
+	at := 0
+	if len(current) > 0 {
+		at = current[len(current)-1].end
+		if nl := strings.IndexByte(existing[at:], '\n'); nl != -1 {
+			at += nl + 1
+		} else {
+			at = len(existing)
+			lines[0] = "\n" + lines[0]
+		}
+	}
+
+	return existing[:at] + strings.Join(lines, "\n") + "\n" + existing[at:]
+}
+
+// topLevelCalls returns the source of every call to callee (including callee.only etc.)
+// made at the top level of code, with its trailing semicolon.
+func topLevelCalls(code string, callee string) []string {
+	var calls []string
+	for _, span := range topLevelCallSpans(code, callee) {
+		calls = append(calls, code[span[0]:span[1]])
+	}
+	return calls
+}
+
+// topLevelCallSpans returns the start and end offsets of the calls topLevelCalls returns.
+func topLevelCallSpans(code string, callee string) [][2]int {
+	start := regexp.MustCompile(`^` + regexp.QuoteMeta(callee) + `(?:\.\w+)*\s*\(`)
+
+	var calls [][2]int
+	depth := 0
+	for i := 0; i < len(code); {
+		if next := skipLiteral(code, i); next != i {
+			i = next
+			continue
+		}
+
+		atStatement := i == 0 || strings.ContainsRune(" \t\n;}", rune(code[i-1]))
+		if depth == 0 && atStatement {
+			if loc := start.FindStringIndex(code[i:]); loc != nil {
+				end := matchClose(code, i+loc[1]-1)
+				if end < len(code) && code[end] == ';' {
+					end++
+				}
+				calls = append(calls, [2]int{i, end})
+				i = end
+				continue
+			}
+		}
+
+		switch code[i] {
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+		}
+		i++
+	}
+
+	return calls
+}
+
+// matchClose returns the offset just past the bracket that closes the one at open.
+func matchClose(code string, open int) int {
+	depth := 0
+	for i := open; i < len(code); {
+		if next := skipLiteral(code, i); next != i {
+			i = next
+			continue
+		}
+		switch code[i] {
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+		i++
+	}
+	return len(code)
+}
+
+// skipLiteral returns the offset just past the string, template literal, regular expression or
+// comment starting at i, or i itself if none starts there.
+func skipLiteral(code string, i int) int {
+	switch {
+	case strings.HasPrefix(code[i:], "//"):
+		if nl := strings.IndexByte(code[i:], '\n'); nl != -1 {
+			return i + nl
+		}
+		return len(code)
+	case strings.HasPrefix(code[i:], "/*"):
+		if end := strings.Index(code[i+2:], "*/"); end != -1 {
+			return i + 2 + end + 2
+		}
+		return len(code)
+	case code[i] == '\'' || code[i] == '"':
+		for j := i + 1; j < len(code); j++ {
+			switch code[j] {
+			case '\\':
+				j++
+			case code[i]:
+				return j + 1
+			case '\n':
+				return j
+			}
+		}
+		return len(code)
+	case code[i] == '`':
+		for j := i + 1; j < len(code); {
+			switch {
+			case code[j] == '\\':
+				j += 2
+			case code[j] == '`':
+				return j + 1
+			case code[j] == '$' && j+1 < len(code) && code[j+1] == '{':
+				j = matchClose(code, j+1)
+			default:
+				j++
+			}
+		}
+		return len(code)
+	case code[i] == '/' && regexAllowed(code, i):
+		inClass := false
+		for j := i + 1; j < len(code); j++ {
+			switch {
+			case code[j] == '\\':
+				j++
+			case code[j] == '\n':
+				// Not a regular expression after all
+				return i
+			case code[j] == '[':
+				inClass = true
+			case code[j] == ']':
+				inClass = false
+			case code[j] == '/' && !inClass:
+				j++
+				for j < len(code) && isIdentByte(code[j]) {
+					j++
+				}
+				return j
+			}
+		}
+		return i
+	}
+	return i
+}
+
+// regexKeywords are the keywords after which a slash starts a regular expression.
+var regexKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true, "new": true,
+	"delete": true, "void": true, "throw": true, "case": true, "do": true, "else": true,
+	"yield": true, "await": true,
+}
+
+// regexAllowed reports whether the slash at i starts a regular expression rather than a division,
+// judging by the token before it.
+func regexAllowed(code string, i int) bool {
+	j := i - 1
+	for j >= 0 && strings.ContainsRune(" \t\r\n", rune(code[j])) {
+		j--
+	}
+	if j < 0 {
+		return true
+	}
+	if isIdentByte(code[j]) {
+		end := j + 1
+		for j >= 0 && isIdentByte(code[j]) {
+			j--
+		}
+		return regexKeywords[code[j+1:end]]
+	}
+	return strings.IndexByte("(,=:[!&|?{};+-*%<>~^", code[j]) != -1
+}
+
+// isIdentByte reports whether c can be part of an identifier.
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
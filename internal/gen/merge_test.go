@@ -0,0 +1,217 @@
+package gen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mergeSource = `export const h = (a: number) => a * 2;
+export function k() { return 1; }
+`
+
+const mergeExisting = `import { h, k } from './x';
+import { describe, it, expect } from 'vitest';
+
+describe('h', () => {
+  it('returns a number', () => { expect(h(1)).toBe(1); });
+});
+
+describe('k', () => {
+  it('returns 1', () => { expect(k()).toBe(1); });
+});
+`
+
+func TestMergeTestsReplacesChangedExport(t *testing.T) {
+	generated := "describe('h', () => {\n  it('doubles', () => { expect(h(2)).toBe(4); });\n});\n"
+
+	merged, err := MergeTests(mergeSource, mergeExisting, generated, []string{"h"}, []string{"h"})
+	if err != nil {
+		t.Fatalf("MergeTests: %v", err)
+	}
+	if strings.Contains(merged, "returns a number") {
+		t.Errorf("outdated describe block of h was kept:\n%s", merged)
+	}
+	if strings.Count(merged, "describe('h'") != 1 || !strings.Contains(merged, "doubles") {
+		t.Errorf("want exactly the new describe block of h:\n%s", merged)
+	}
+	if !strings.Contains(merged, "it('returns 1'") {
+		t.Errorf("tests of k were lost:\n%s", merged)
+	}
+	if strings.Index(merged, "doubles") > strings.Index(merged, "describe('k'") {
+		t.Errorf("new block of h didn't take the place of the old one:\n%s", merged)
+	}
+}
+
+func TestMergeTestsAppendsUntestedExport(t *testing.T) {
+	existing := "import { h } from './x';\n\ndescribe('h', () => {\n  it('works', () => {});\n});\n"
+	generated := "import { k } from './x';\n\ndescribe('k', () => {\n  it('returns 1', () => {});\n});\n"
+
+	merged, err := MergeTests(mergeSource, existing, generated, []string{"k"}, nil)
+	if err != nil {
+		t.Fatalf("MergeTests: %v", err)
+	}
+	want := "import { h } from './x';\nimport { k } from './x';\n\ndescribe('h', () => {\n  it('works', () => {});\n});\n\ndescribe('k', () => {\n  it('returns 1', () => {});\n});\n"
+	if merged != want {
+		t.Errorf("merged =\n%s\nwant\n%s", merged, want)
+	}
+}
+
+func TestMergeTestsWithoutMatchingBlock(t *testing.T) {
+	generated := "describe('h', () => {\n  it('works', () => {});\n});\n"
+	if _, err := MergeTests(mergeSource, mergeExisting, generated, []string{"k"}, nil); err == nil {
+		t.Error("MergeTests took a block for an export it wasn't asked for")
+	}
+}
+
+func TestMergeTestsRemovesDuplicateOutdatedBlocks(t *testing.T) {
+	existing := mergeExisting + "\ndescribe('h', () => {\n  it('is defined', () => {});\n});\n"
+	generated := "describe('h', () => {\n  it('doubles', () => {});\n});\n"
+
+	merged, err := MergeTests(mergeSource, existing, generated, []string{"h"}, []string{"h"})
+	if err != nil {
+		t.Fatalf("MergeTests: %v", err)
+	}
+	if strings.Count(merged, "describe('h'") != 1 || strings.Contains(merged, "is defined") {
+		t.Errorf("want a single describe block of h:\n%s", merged)
+	}
+}
+
+func TestUntestedExports(t *testing.T) {
+	tests := []struct {
+		name     string
+		testCode string
+		want     []string
+	}{
+		{"no tests", "", []string{"k", "h"}},
+		{"describe titles", "describe('h', () => {});\ndescribe(\"k\", () => {});", nil},
+		{"it title mentions", "describe('x', () => { it('k returns 1', () => {}); });", []string{"h"}},
+		{"template title", "test(`h doubles`, () => {});", []string{"k"}},
+		{"symbol name", "describe(k.name, () => {});", []string{"h"}},
+		{"whole words only", "describe('hk', () => { it('khaki', () => {}); });", []string{"k", "h"}},
+		{"modifiers", "describe.skip('h', () => {});\nit.only('k', () => {});", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UntestedExports(mergeSource, tt.testCode); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UntestedExports() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopLevelCalls(t *testing.T) {
+	first := "describe('a', () => {\n" +
+		"  it('has ) in a string', () => { expect(')').toBe(\"}\"); });\n" +
+		"  const re = /[)}\\]]/g;\n" +
+		"  const ratio = (1) / 2 / 3;\n" +
+		"  // a comment with }) in it\n" +
+		"  /* and ({ here */\n" +
+		"  const s = `${'}'} and ${fn({ a: 1 })} text )`;\n" +
+		"  describe('nested', () => {});\n" +
+		"});"
+	second := "describe.only(\"b\", () => {})"
+	code := "import { a } from './a';\n\n" + first + "\n\n" + second + "\nconst d = describe;\nfoo(describe('c', () => {}));\n"
+
+	got := topLevelCalls(code, "describe")
+	if want := []string{first, second}; !reflect.DeepEqual(got, want) {
+		t.Errorf("topLevelCalls() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestSkipLiteral(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  string
+		literal string // what skipLiteral skips, starting after before
+		after   string
+	}{
+		{"single quotes", "", "'a)b'", " + c"},
+		{"double quotes with escape", "", `"a\"}"`, ")"},
+		{"unterminated string", "", "'a(", "\nb"},
+		{"template literal", "", "`a ${ {b: '`'} } c`", " d"},
+		{"line comment", "x; ", "// })", "\ny"},
+		{"block comment", "", "/* }) */", " x"},
+		{"regex with brackets", "x = ", `/[)/\]]\//gi`, "; y"},
+		{"regex after keyword", "return ", "/}/", ";"},
+		{"division", "a ", "", "/ b / c"},
+		{"division after parenthesis", "(a) ", "", "/ 2 /g"},
+		{"slash without closing slash", "(", "", "/ b\n)"},
+		{"not a literal", "", "", "describe()"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := tt.before + tt.literal + tt.after
+			if got, want := skipLiteral(code, len(tt.before)), len(tt.before)+len(tt.literal); got != want {
+				t.Errorf("skipLiteral(%q, %d) = %d, want %d", code, len(tt.before), got, want)
+			}
+		})
+	}
+}
+
+func TestInsertImports(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  string
+		generated string
+		body      string
+		want      string
+	}{
+		{
+			"after the last import",
+			"import { h } from './x';\nimport { vi } from 'vitest';\n\ndescribe('h', () => {});\n",
+			"import { k } from './x';",
+			"k()",
+			"import { h } from './x';\nimport { vi } from 'vitest';\nimport { k } from './x';\n\ndescribe('h', () => {});\n",
+		},
+		{
+			"source module as existing imports it",
+			"import { h } from '../src/x';\n",
+			"import { k } from './x';",
+			"k()",
+			"import { h } from '../src/x';\nimport { k } from '../src/x';\n",
+		},
+		{
+			"after a multi-line import",
+			"import {\n  h,\n} from './x';\ndescribe('h', () => {});\n",
+			"import { k } from './x';",
+			"k()",
+			"import {\n  h,\n} from './x';\nimport { k } from './x';\ndescribe('h', () => {});\n",
+		},
+		{
+			"no imports",
+			"describe('h', () => {});\n",
+			"import { vi } from 'vitest';",
+			"vi.fn()",
+			"import { vi } from 'vitest';\ndescribe('h', () => {});\n",
+		},
+		{
+			"last line without newline",
+			"import { h } from './x';",
+			"import { k } from './x';",
+			"k()",
+			"import { h } from './x';\nimport { k } from './x';\n",
+		},
+		{
+			"only unbound names the body uses",
+			"import { h } from './x';\n",
+			"import { h, k, j } from './x';\nimport * as fs from 'fs';\nimport path from 'path';",
+			"h(); k(); fs.readFileSync()",
+			"import { h } from './x';\nimport { k } from './x';\nimport * as fs from 'fs';\n",
+		},
+		{
+			"nothing to add",
+			"import { h } from './x';\n",
+			"import { h } from './x';",
+			"h()",
+			"import { h } from './x';\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := insertImports(mergeSource, tt.existing, parseImports(tt.generated), tt.body); got != tt.want {
+				t.Errorf("insertImports() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
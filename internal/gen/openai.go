@@ -0,0 +1,144 @@
+package gen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tanerincode/auto-test-generator/internal/framework"
+)
+
+// OpenAI defaults, used when the configuration leaves them unset.
+const (
+	DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+	DefaultOpenAIModel   = "gpt-4o-mini"
+	// DefaultOpenAIConcurrency is how many requests are in flight at once.
+	DefaultOpenAIConcurrency = 8
+	// OpenAIKeyEnv is the environment variable holding the API key.
+	OpenAIKeyEnv = "OPENAI_API_KEY"
+)
+
+// OpenAIConfig selects an OpenAI-compatible chat completions endpoint.
+type OpenAIConfig struct {
+	BaseURL string
+	Model   string
+	APIKey  string
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// openAISystemPrompt keeps answers to the test file itself.
+const openAISystemPrompt = "You write TypeScript unit tests. Reply with only the complete test file, without explanations."
+
+// GenerateTestWithOpenAI generates tests with an OpenAI-compatible chat completions API, using
+// the same prompt as Auggie. A non-nil focus limits the prompt to the given exports. The returned
+// usage is the one the API reports, or an estimate if it reports none; failed requests have none.
+func GenerateTestWithOpenAI(ctx context.Context, logger *slog.Logger, cfg OpenAIConfig, filePath string, testPath string, code string, fw framework.Framework, projectContext string, focus *Focus) (string, Usage, error) {
+	if cfg.APIKey == "" {
+		return "", Usage{}, fmt.Errorf("openai: no API key; set %s", OpenAIKeyEnv)
+	}
+
+	prompt := buildAugmentPrompt(filePath, testPath, code, fw, projectContext, focus)
+	body, err := json.Marshal(chatRequest{
+		Model: cfg.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: openAISystemPrompt},
+			{Role: "user", Content: prompt},
+		},
+	})
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("openai: failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(cfg.BaseURL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("openai: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+
+	logger.Info("generating tests", "file", filePath, "provider", "openai", "model", cfg.Model)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", Usage{}, fmt.Errorf("openai: request stopped: %w", ctx.Err())
+		}
+		// Connection failures are worth another try
+		return "", Usage{}, &ProviderError{Err: fmt.Errorf("openai: %w", err), Retryable: true}
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", Usage{}, &ProviderError{Err: fmt.Errorf("openai: failed to read response: %w", err), Retryable: true}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(content))
+		if len(msg) > 300 {
+			msg = msg[:300] + "…"
+		}
+		return "", Usage{}, &ProviderError{
+			Err:        fmt.Errorf("openai: %s: %s", resp.Status, msg),
+			Retryable:  resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	var parsed chatResponse
+	if err := json.Unmarshal(content, &parsed); err != nil {
+		return "", Usage{}, fmt.Errorf("openai: failed to parse response: %w", err)
+	}
+
+	// An empty answer still used the tokens the API reports
+	var usage Usage
+	if parsed.Usage != nil {
+		usage = Usage{PromptTokens: parsed.Usage.PromptTokens, CompletionTokens: parsed.Usage.CompletionTokens}
+	}
+	if len(parsed.Choices) == 0 || strings.TrimSpace(parsed.Choices[0].Message.Content) == "" {
+		return "", usage, &ProviderError{Err: fmt.Errorf("openai: empty response"), Retryable: true}
+	}
+
+	answer := parsed.Choices[0].Message.Content
+	if parsed.Usage == nil {
+		usage = estimatedUsage(openAISystemPrompt+prompt, answer)
+	}
+
+	logger.Info("generated tests", "file", filePath, "provider", "openai")
+	return strings.TrimSpace(stripCodeFence(answer)) + "\n", usage, nil
+}
+
+// retryAfter parses a Retry-After header given in seconds, or returns zero.
+func retryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(header))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
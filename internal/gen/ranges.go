@@ -0,0 +1,172 @@
+package gen
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tanerincode/auto-test-generator/internal/diff"
+)
+
+// symbolRange is the lines a declaration spans, with the methods of a class.
+type symbolRange struct {
+	name    string
+	lines   diff.Range
+	methods []symbolRange
+}
+
+// exportDeclPattern matches the start of a top-level export declaration.
+var exportDeclPattern = regexp.MustCompile(`(?m)^export\s+(default\s+)?(?:declare\s+)?(?:abstract\s+)?(?:async\s+)?(function\*?|class|const|let|var|interface|type|enum)?\s*(\w+)?`)
+
+// methodPattern matches the start of a class member with a body, at the start of a line.
+var methodPattern = regexp.MustCompile(`^[ \t]*(?:(?:public|private|protected|static|async|override|get|set)\s+)*\*?\s*(\w+)\s*(?:<[^>(]*>)?\s*\(`)
+
+// exportRanges returns the lines spanned by each export declaration of code.
+func exportRanges(code string) []symbolRange {
+	var ranges []symbolRange
+	for _, loc := range exportDeclPattern.FindAllStringSubmatchIndex(code, -1) {
+		isDefault := loc[2] != -1
+		kind, name := "", "default"
+		if loc[4] != -1 {
+			kind = code[loc[4]:loc[5]]
+		}
+		if loc[6] != -1 {
+			name = code[loc[6]:loc[7]]
+		}
+		// Re-exports such as export { a } declare nothing
+		if !isDefault && kind == "" {
+			continue
+		}
+
+		end := declarationEnd(code, loc[0])
+		r := symbolRange{name: name, lines: diff.Range{Start: lineAt(code, loc[0]), End: lineAt(code, end-1)}}
+		if kind == "class" {
+			r.methods = methodRanges(code, loc[0], end)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// declarationEnd returns the offset just past the top-level declaration starting at start: at a
+// semicolon outside brackets, or before the next line that starts a new statement.
+func declarationEnd(code string, start int) int {
+	depth := 0
+	for i := start; i < len(code); {
+		if next := skipLiteral(code, i); next != i {
+			i = next
+			continue
+		}
+		switch code[i] {
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+		case ';':
+			if depth == 0 {
+				return i + 1
+			}
+		case '\n':
+			if depth == 0 && i+1 < len(code) && !strings.ContainsRune(" \t\r\n.)}]", rune(code[i+1])) {
+				return i
+			}
+		}
+		i++
+	}
+	return len(code)
+}
+
+// methodRanges returns the lines spanned by the methods of the class declared in code[start:end].
+func methodRanges(code string, start int, end int) []symbolRange {
+	open := strings.IndexByte(code[start:end], '{')
+	if open == -1 {
+		return nil
+	}
+	open += start
+
+	var methods []symbolRange
+	depth := 0
+	for i := open + 1; i < end; {
+		if next := skipLiteral(code, i); next != i {
+			i = next
+			continue
+		}
+
+		// Members start lines directly inside the class body
+		if depth == 0 && (code[i-1] == '\n' || code[i-1] == '{') {
+			if match := methodPattern.FindStringSubmatchIndex(code[i:end]); match != nil {
+				paramsEnd := matchClose(code, i+match[1]-1)
+				body := strings.IndexByte(code[paramsEnd:end], '{')
+				if body != -1 && !strings.Contains(code[paramsEnd:paramsEnd+body], ";") {
+					methodEnd := matchClose(code, paramsEnd+body)
+					methods = append(methods, symbolRange{
+						name:  code[i+match[2] : i+match[3]],
+						lines: diff.Range{Start: lineAt(code, i), End: lineAt(code, methodEnd-1)},
+					})
+					i = methodEnd
+					continue
+				}
+			}
+		}
+
+		switch code[i] {
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+		}
+		i++
+	}
+	return methods
+}
+
+// lineAt returns the line number, from 1, of the byte at offset.
+func lineAt(code string, offset int) int {
+	return strings.Count(code[:max(offset, 0)], "\n") + 1
+}
+
+// touches reports whether any of lines falls in r.
+func touches(r diff.Range, lines []diff.Range) bool {
+	for _, l := range lines {
+		if l.Start <= r.End && l.End >= r.Start {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangedExports returns the exports of code whose declarations contain one of lines, in order of
+// appearance. Changes outside export declarations, such as in private helpers, touch no export.
+func ChangedExports(code string, lines []diff.Range) []string {
+	known := make(map[string]bool)
+	for _, name := range Exports(code) {
+		known[name] = true
+	}
+
+	var changed []string
+	seen := make(map[string]bool)
+	for _, r := range exportRanges(code) {
+		if known[r.name] && !seen[r.name] && touches(r.lines, lines) {
+			seen[r.name] = true
+			changed = append(changed, r.name)
+		}
+	}
+	return changed
+}
+
+// changedMethods returns the changed methods of the class exports of code named in exports, as
+// Class.method.
+func changedMethods(code string, exports []string, lines []diff.Range) []string {
+	var methods []string
+	for _, r := range exportRanges(code) {
+		if !slices.Contains(exports, r.name) {
+			continue
+		}
+		for _, m := range r.methods {
+			if touches(m.lines, lines) {
+				methods = append(methods, r.name+"."+m.name)
+			}
+		}
+	}
+	return methods
+}
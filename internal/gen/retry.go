@@ -0,0 +1,76 @@
+package gen
+
+import (
+	"errors"
+	"math/rand"
+	"regexp"
+	"time"
+)
+
+// ProviderError is a failed provider call, classified as worth retrying or not.
+type ProviderError struct {
+	Err error
+	// Retryable marks transient failures such as rate limits and network errors.
+	Retryable bool
+	// RetryAfter is how long the provider asked to wait before the next call, if it did.
+	RetryAfter time.Duration
+}
+
+// Error returns the message of the wrapped error.
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether err is a transient provider failure worth retrying. Errors that
+// weren't classified are not retried.
+func Retryable(err error) bool {
+	var pe *ProviderError
+	return errors.As(err, &pe) && pe.Retryable
+}
+
+// RetryAfter returns how long the provider that failed with err asked to wait, or zero.
+func RetryAfter(err error) time.Duration {
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		return pe.RetryAfter
+	}
+	return 0
+}
+
+// transientPattern matches the messages of transient failures: rate limits, overloaded or
+// unavailable servers, timeouts and network errors.
+var transientPattern = regexp.MustCompile(`(?i)rate.?limit|too many requests|\b(429|502|503|504)\b|overloaded|temporarily unavailable|timed? ?out|ETIMEDOUT|ECONNRESET|ECONNREFUSED|EAI_AGAIN|socket hang up|network`)
+
+// classify wraps err as a ProviderError, retryable if msg (the provider's own output) or err
+// reads like a transient failure.
+func classify(err error, msg string) *ProviderError {
+	return &ProviderError{Err: err, Retryable: transientPattern.MatchString(msg) || transientPattern.MatchString(err.Error())}
+}
+
+// Backoff computes exponentially growing waits between retries, with jitter so workers that
+// hit the same rate limit don't retry in lockstep.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// DefaultBackoff waits about 2s, 4s, 8s, ... up to 30s.
+var DefaultBackoff = Backoff{Base: 2 * time.Second, Max: 30 * time.Second}
+
+// Delay returns the wait before retry n (1 for the first retry): a random duration between
+// half and all of Base*2^(n-1), capped at Max.
+func (b Backoff) Delay(n int) time.Duration {
+	d := b.Base
+	for i := 1; i < n && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
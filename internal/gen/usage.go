@@ -0,0 +1,35 @@
+package gen
+
+// Usage is the token usage of provider calls.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	// Estimated is set when a provider didn't report its usage and the counts were estimated
+	// from the text sent and received.
+	Estimated bool
+}
+
+// Add returns the sum of u and v, estimated if either is.
+func (u Usage) Add(v Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + v.PromptTokens,
+		CompletionTokens: u.CompletionTokens + v.CompletionTokens,
+		Estimated:        u.Estimated || v.Estimated,
+	}
+}
+
+// Total returns the prompt and completion tokens together.
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// EstimateTokens estimates the number of tokens in text, counting about four characters per
+// token.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// estimatedUsage returns the estimated usage of a call that sent prompt and received completion.
+func estimatedUsage(prompt string, completion string) Usage {
+	return Usage{PromptTokens: EstimateTokens(prompt), CompletionTokens: EstimateTokens(completion), Estimated: true}
+}
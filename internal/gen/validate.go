@@ -0,0 +1,59 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateTest checks that generated test code looks like a complete test file: its brackets
+// balance and it calls describe, it or test at the top level. It catches truncated output and
+// answers in prose before they are written.
+func ValidateTest(code string) error {
+	code = stripCodeFence(code)
+
+	var open []int
+	for i := 0; i < len(code); {
+		if next := skipLiteral(code, i); next != i {
+			i = next
+			continue
+		}
+		switch code[i] {
+		case '(', '{', '[':
+			open = append(open, i)
+		case ')', '}', ']':
+			if len(open) == 0 || code[open[len(open)-1]] != opening(code[i]) {
+				return fmt.Errorf("unexpected %q on line %d", code[i], lineOf(code, i))
+			}
+			open = open[:len(open)-1]
+		}
+		i++
+	}
+	if len(open) > 0 {
+		at := open[len(open)-1]
+		return fmt.Errorf("unclosed %q from line %d; the output may be truncated", code[at], lineOf(code, at))
+	}
+
+	for _, callee := range []string{"describe", "it", "test"} {
+		if len(topLevelCalls(code, callee)) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("no top-level describe, it or test call")
+}
+
+// opening returns the bracket that the closing bracket c closes.
+func opening(c byte) byte {
+	switch c {
+	case ')':
+		return '('
+	case '}':
+		return '{'
+	default:
+		return '['
+	}
+}
+
+// lineOf returns the 1-based line of offset i in code.
+func lineOf(code string, i int) int {
+	return strings.Count(code[:i], "\n") + 1
+}
@@ -0,0 +1,79 @@
+package limit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter bounds how many calls run at once and, optionally, how many start per minute. Calls
+// are spread evenly over the minute rather than sent in bursts.
+type Limiter struct {
+	slots    chan struct{}
+	interval time.Duration // between call starts; 0 for no rate limit
+
+	mu   sync.Mutex
+	next time.Time // earliest start of the next call
+}
+
+// New returns a limiter allowing concurrency simultaneous calls and perMinute calls a minute,
+// or any number a minute if perMinute is 0.
+func New(concurrency int, perMinute int) *Limiter {
+	l := &Limiter{slots: make(chan struct{}, max(concurrency, 1))}
+	if perMinute > 0 {
+		l.interval = time.Minute / time.Duration(perMinute)
+	}
+	return l
+}
+
+// Concurrency returns the number of simultaneous calls allowed.
+func (l *Limiter) Concurrency() int {
+	return cap(l.slots)
+}
+
+// Acquire waits for a free slot and for the turn of the next call, then takes the slot, which
+// Release gives back. It returns ctx.Err() if ctx is done first.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	start := time.Now()
+	if l.next.After(start) {
+		start = l.next
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	wait := time.Until(start)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		<-l.slots
+		return ctx.Err()
+	}
+}
+
+// Release gives back a slot taken by Acquire.
+func (l *Limiter) Release() {
+	<-l.slots
+}
+
+// Pause holds back calls not yet started for d, such as when the provider asked to wait before
+// the next request.
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.next) {
+		l.next = until
+	}
+}
@@ -0,0 +1,110 @@
+package limit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterConcurrency(t *testing.T) {
+	l := New(2, 0)
+	if l.Concurrency() != 2 {
+		t.Fatalf("Concurrency() = %d, want 2", l.Concurrency())
+	}
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.Acquire(context.Background()); err != nil {
+				t.Error(err)
+				return
+			}
+			defer l.Release()
+
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak)
+	}
+}
+
+func TestLimiterZeroConcurrency(t *testing.T) {
+	if got := New(0, 0).Concurrency(); got != 1 {
+		t.Errorf("Concurrency() = %d, want 1", got)
+	}
+}
+
+func TestLimiterCanceledWaitingForSlot(t *testing.T) {
+	l := New(1, 0)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	// 600 a minute is one call every 100ms
+	l := New(3, 600)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("3 calls started within %s, want them 100ms apart", elapsed)
+	}
+}
+
+func TestLimiterCanceledWaitingForTurnFreesSlot(t *testing.T) {
+	l := New(1, 60)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	l.Release()
+
+	// The next turn is a second away
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := len(l.slots); n != 0 {
+		t.Errorf("%d slot(s) still taken after a canceled Acquire", n)
+	}
+}
+
+func TestLimiterPause(t *testing.T) {
+	l := New(1, 0)
+	l.Pause(100 * time.Millisecond)
+
+	start := time.Now()
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Acquire() returned after %s, want it to wait out the pause", elapsed)
+	}
+}
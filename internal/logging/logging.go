@@ -0,0 +1,176 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Format is a log output format.
+type Format string
+
+const (
+	// Text is one human-readable line per record: the message followed by key=value attributes.
+	Text Format = "text"
+	// JSON is one JSON object per record.
+	JSON Format = "json"
+)
+
+// ParseFormat validates a log format name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case Text, JSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid log format: %s (must be text or json)", name)
+	}
+}
+
+// New returns a logger writing records of at least level to w in the given format.
+// Every record is written with a single call to w, so lines of concurrent workers never interleave.
+func New(w io.Writer, level slog.Level, format Format) *slog.Logger {
+	if format == JSON {
+		return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+	}
+	return slog.New(&textHandler{out: &lockedWriter{w: w}, level: level})
+}
+
+// lockedWriter serializes writes shared by a handler and the handlers derived from it.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// Gate passes writes through to a writer, except while Hold holds the terminal, such as for a
+// prompt: then they are kept, and written out on release.
+type Gate struct {
+	w    io.Writer
+	hold sync.Mutex // taken by whoever holds the terminal
+
+	mu   sync.Mutex
+	held bool
+	buf  bytes.Buffer
+}
+
+// NewGate returns a gate writing to w.
+func NewGate(w io.Writer) *Gate {
+	return &Gate{w: w}
+}
+
+func (g *Gate) Write(p []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.held {
+		return g.buf.Write(p)
+	}
+	return g.w.Write(p)
+}
+
+// Take waits until no one else holds the terminal, then holds it, letting writes through, until
+// the returned function releases it. It suits output that logs may go along with, such as a test
+// run's.
+func (g *Gate) Take() (release func()) {
+	g.hold.Lock()
+	return g.hold.Unlock
+}
+
+// Hold is like Take, but also holds writes back until the terminal is released, as a prompt needs.
+func (g *Gate) Hold() (release func()) {
+	g.hold.Lock()
+	g.mu.Lock()
+	g.held = true
+	g.mu.Unlock()
+
+	return func() {
+		g.mu.Lock()
+		g.held = false
+		g.w.Write(g.buf.Bytes())
+		g.buf.Reset()
+		g.mu.Unlock()
+		g.hold.Unlock()
+	}
+}
+
+// textHandler formats records without timestamps, prefixing warnings and errors with their level.
+type textHandler struct {
+	out    *lockedWriter
+	level  slog.Level
+	attrs  string // preformatted attributes added with WithAttrs
+	prefix string // group prefix of attribute keys
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var line strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		line.WriteString("error: ")
+	case r.Level >= slog.LevelWarn:
+		line.WriteString("warning: ")
+	case r.Level < slog.LevelInfo:
+		line.WriteString("debug: ")
+	}
+	line.WriteString(r.Message)
+	line.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&line, h.prefix, a)
+		return true
+	})
+	line.WriteByte('\n')
+
+	_, err := h.out.Write([]byte(line.String()))
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var line strings.Builder
+	for _, a := range attrs {
+		appendAttr(&line, h.prefix, a)
+	}
+	clone := *h
+	clone.attrs += line.String()
+	return &clone
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+// appendAttr writes a as " key=value", quoting values that contain spaces or quotes.
+func appendAttr(line *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			appendAttr(line, prefix+a.Key+".", ga)
+		}
+		return
+	}
+
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	line.WriteString(" " + prefix + a.Key + "=" + value)
+}
@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestGateHoldsLogsBack(t *testing.T) {
+	var out bytes.Buffer
+	gate := NewGate(&out)
+	logger := New(gate, slog.LevelInfo, Text)
+
+	logger.Info("before")
+	release := gate.Hold()
+	logger.Info("during", "file", "a.ts")
+	if got := out.String(); got != "before\n" {
+		t.Errorf("output while held = %q, want only the line before", got)
+	}
+	release()
+	logger.Info("after")
+
+	if got, want := out.String(), "before\nduring file=a.ts\nafter\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestGateTakeLetsLogsThrough(t *testing.T) {
+	var out bytes.Buffer
+	gate := NewGate(&out)
+
+	release := gate.Take()
+	gate.Write([]byte("log\n"))
+	if out.String() != "log\n" {
+		t.Errorf("output = %q, want the log written at once", out.String())
+	}
+
+	// A prompt waits for the terminal to be released
+	held := make(chan func())
+	go func() { held <- gate.Hold() }()
+	select {
+	case <-held:
+		t.Fatal("Hold returned while the terminal was taken")
+	case <-time.After(20 * time.Millisecond):
+	}
+	release()
+	select {
+	case releaseHold := <-held:
+		releaseHold()
+	case <-time.After(time.Second):
+		t.Fatal("Hold still waiting after the terminal was released")
+	}
+}
@@ -0,0 +1,254 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tanerincode/auto-test-generator/internal/diff"
+)
+
+// Policy decides what happens when a generated test file would replace an existing file.
+type Policy string
+
+const (
+	// Skip leaves existing files alone.
+	Skip Policy = "skip"
+	// Backup copies an existing file to a timestamped .bak file before replacing it.
+	Backup Policy = "backup"
+	// Interactive shows a diff for every file and asks to accept, reject or edit it.
+	Interactive Policy = "interactive"
+)
+
+// ParsePolicy validates a write policy name.
+func ParsePolicy(name string) (Policy, error) {
+	switch p := Policy(name); p {
+	case Skip, Backup, Interactive:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid write policy: %s (must be skip, backup, or interactive)", name)
+	}
+}
+
+// Outcome is what happened to a single file.
+type Outcome int
+
+const (
+	Created Outcome = iota
+	Updated
+	Unchanged
+	Skipped
+	Rejected
+)
+
+// String returns the outcome name.
+func (o Outcome) String() string {
+	switch o {
+	case Created:
+		return "created"
+	case Updated:
+		return "updated"
+	case Unchanged:
+		return "unchanged"
+	case Skipped:
+		return "skipped"
+	default:
+		return "rejected"
+	}
+}
+
+// Written reports whether the file on disk now holds the generated content.
+func (o Outcome) Written() bool {
+	return o == Created || o == Updated
+}
+
+// Writer writes generated test files according to a Policy.
+type Writer struct {
+	Policy Policy
+	In     *bufio.Reader
+	Out    io.Writer
+	// Color enables ANSI colors in interactive diffs.
+	Color bool
+	// Hold, if set, takes the terminal for an interactive review, holding other output back
+	// until the returned function releases it.
+	Hold func() (release func())
+}
+
+// NewWriter returns a Writer prompting on stdin and stdout.
+func NewWriter(policy Policy) *Writer {
+	return &Writer{
+		Policy: policy,
+		In:     bufio.NewReader(os.Stdin),
+		Out:    os.Stdout,
+		Color:  IsTerminal(os.Stdout),
+	}
+}
+
+// Write writes content to path according to the policy. With update, content is meant to
+// replace the existing file (as in augment mode), so the skip policy does not apply to it.
+func (w *Writer) Write(path string, content string, update bool) (Outcome, error) {
+	old, err := os.ReadFile(path)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return Skipped, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if exists && string(old) == content {
+		return Unchanged, nil
+	}
+
+	switch w.Policy {
+	case Backup:
+		if exists {
+			backup := fmt.Sprintf("%s.%s.bak", path, time.Now().Format("20060102-150405"))
+			if err := WriteFileAtomic(backup, old, 0644); err != nil {
+				return Skipped, fmt.Errorf("failed to back up %s: %w", path, err)
+			}
+		}
+	case Interactive:
+		reviewed, ok, err := w.review(path, string(old), content)
+		if err != nil {
+			return Skipped, err
+		}
+		if !ok {
+			return Rejected, nil
+		}
+		content = reviewed
+	default:
+		if exists && !update {
+			return Skipped, nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return Skipped, fmt.Errorf("failed to create directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := WriteFileAtomic(path, []byte(content), 0644); err != nil {
+		return Skipped, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if exists {
+		return Updated, nil
+	}
+	return Created, nil
+}
+
+// review shows the diff for path and asks whether to accept, reject or edit the new content.
+func (w *Writer) review(path string, old string, content string) (string, bool, error) {
+	if w.Hold != nil {
+		defer w.Hold()()
+	}
+	for {
+		d := diff.Unified(path, path, old, content)
+		if old == "" {
+			d = diff.Unified("/dev/null", path, old, content)
+		}
+		if w.Color {
+			d = diff.Colorize(d)
+		}
+		fmt.Fprintf(w.Out, "\n%s", d)
+		fmt.Fprintf(w.Out, "Write %s? [a]ccept, [r]eject, [e]dit: ", path)
+
+		answer, err := w.In.ReadString('\n')
+		if err != nil && answer == "" {
+			return "", false, fmt.Errorf("no answer for %s: %w", path, err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "a", "accept", "y", "yes":
+			return content, true, nil
+		case "r", "reject", "n", "no":
+			return "", false, nil
+		case "e", "edit":
+			edited, err := edit(path, content)
+			if err != nil {
+				fmt.Fprintf(w.Out, "edit failed: %v\n", err)
+				continue
+			}
+			content = edited
+		}
+	}
+}
+
+// edit opens content in $VISUAL or $EDITOR (vi by default) and returns the saved result.
+func edit(path string, content string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// Keep the extension so the editor picks the right syntax
+	tmp, err := os.CreateTemp("", "autotest-*"+filepath.Ext(path))
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	tmp.Close()
+
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], tmp.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w", editor, err)
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(edited), nil
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it into place,
+// so readers never see a partially written file. A new file gets perm; an existing one keeps its
+// mode.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// IsTerminal reports whether f is a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
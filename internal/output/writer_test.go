@@ -0,0 +1,72 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAtomicKeepsMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.spec.ts")
+	if err := os.WriteFile(path, []byte("old"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFileAtomic(path, []byte("new"), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0750 {
+		t.Errorf("mode = %o, want 750", mode)
+	}
+}
+
+func TestWriteFileAtomicNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.spec.ts")
+	if err := WriteFileAtomic(path, []byte("new"), 0640); err != nil {
+		t.Fatalf("WriteFileAtomic: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0640 {
+		t.Errorf("mode = %o, want 640", mode)
+	}
+}
+
+func TestInteractiveReviewHoldsTerminal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.spec.ts")
+	var out bytes.Buffer
+	var shown string
+	holds := 0
+	w := &Writer{
+		Policy: Interactive,
+		In:     bufio.NewReader(strings.NewReader("a\n")),
+		Out:    &out,
+		Hold: func() func() {
+			holds++
+			return func() { shown = out.String() }
+		},
+	}
+
+	outcome, err := w.Write(path, "test\n", false)
+	if err != nil || outcome != Created {
+		t.Fatalf("Write() = %v, %v; want created", outcome, err)
+	}
+	if holds != 1 {
+		t.Errorf("terminal held %d times, want once", holds)
+	}
+	if !strings.Contains(shown, "+test") || !strings.Contains(shown, "Write "+path+"?") {
+		t.Errorf("diff and question were not shown while the terminal was held: %q", shown)
+	}
+}
@@ -0,0 +1,193 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often progress is redrawn on a terminal and logged otherwise.
+const (
+	TerminalInterval = 200 * time.Millisecond
+	LogInterval      = 10 * time.Second
+)
+
+// Tracker counts the files of a run as workers start and finish them. It is safe for
+// concurrent use.
+type Tracker struct {
+	mu      sync.Mutex
+	total   int
+	done    int
+	failed  int
+	active  []string
+	started time.Time
+}
+
+// NewTracker returns a tracker of a run of total files starting now.
+func NewTracker(total int) *Tracker {
+	return &Tracker{total: total, started: time.Now()}
+}
+
+// Start marks file as in progress.
+func (t *Tracker) Start(file string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active = append(t.active, file)
+}
+
+// Finish marks file as done, or as failed.
+func (t *Tracker) Finish(file string, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, f := range t.active {
+		if f == file {
+			t.active = append(t.active[:i], t.active[i+1:]...)
+			break
+		}
+	}
+	if failed {
+		t.failed++
+	} else {
+		t.done++
+	}
+}
+
+// Snapshot is the progress of a run at one point in time.
+type Snapshot struct {
+	Total   int
+	Done    int
+	Failed  int
+	Active  []string // files in progress, oldest first
+	Elapsed time.Duration
+	ETA     time.Duration // zero until the first file finishes
+}
+
+// Snapshot returns the current progress.
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := Snapshot{
+		Total:   t.total,
+		Done:    t.done,
+		Failed:  t.failed,
+		Active:  append([]string(nil), t.active...),
+		Elapsed: time.Since(t.started),
+	}
+	if finished := s.Done + s.Failed; finished > 0 && finished < s.Total {
+		s.ETA = s.Elapsed / time.Duration(finished) * time.Duration(s.Total-finished)
+	}
+	return s
+}
+
+// String formats the counts and times of the snapshot, without the active files.
+func (s Snapshot) String() string {
+	line := fmt.Sprintf("[%d/%d] %d done, %d failed, %d in progress, %s elapsed",
+		s.Done+s.Failed, s.Total, s.Done, s.Failed, len(s.Active), formatDuration(s.Elapsed))
+	if s.ETA > 0 {
+		line += ", ETA " + formatDuration(s.ETA)
+	}
+	return line
+}
+
+// formatDuration formats d as m:ss.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// Terminal keeps a status line at the bottom of a terminal. Lines written through it are
+// printed above the status line, which is redrawn after them.
+type Terminal struct {
+	mu     sync.Mutex
+	w      io.Writer
+	width  int
+	status string
+}
+
+// NewTerminal returns a terminal writing to w. The status line is cut to the width from
+// $COLUMNS, or 80 columns, so it never wraps.
+func NewTerminal(w io.Writer) *Terminal {
+	width, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || width < 20 {
+		width = 80
+	}
+	return &Terminal{w: w, width: width}
+}
+
+// Write clears the status line, writes p and redraws the status line.
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.status == "" {
+		return t.w.Write(p)
+	}
+	_, err := io.WriteString(t.w, "\r\033[K"+string(p)+t.status)
+	return len(p), err
+}
+
+// SetStatus replaces the status line; an empty status clears it.
+func (t *Terminal) SetStatus(status string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if runes := []rune(status); len(runes) > t.width-1 {
+		status = string(runes[:t.width-2]) + "…"
+	}
+	t.status = status
+	io.WriteString(t.w, "\r\033[K"+status)
+}
+
+// Watch reports the progress of t until the returned stop function is called. On a terminal
+// the status line is redrawn with the files in progress; without one, progress is logged as
+// a plain line every LogInterval.
+func Watch(t *Tracker, term *Terminal, logger *slog.Logger) (stop func()) {
+	interval := LogInterval
+	if term != nil {
+		interval = TerminalInterval
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				if term != nil {
+					term.SetStatus("")
+				}
+				return
+			case <-ticker.C:
+				s := t.Snapshot()
+				if term != nil {
+					status := s.String()
+					if len(s.Active) > 0 {
+						status += ": " + strings.Join(s.Active, ", ")
+					}
+					term.SetStatus(status)
+					continue
+				}
+				args := []interface{}{"finished", s.Done + s.Failed, "total", s.Total, "done", s.Done, "failed", s.Failed,
+					"inProgress", strings.Join(s.Active, ","), "elapsed", formatDuration(s.Elapsed)}
+				if s.ETA > 0 {
+					args = append(args, "eta", formatDuration(s.ETA))
+				}
+				logger.Info("progress", args...)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
@@ -0,0 +1,84 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// junitSuites is the root element of a JUnit XML report.
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// junit renders the report as JUnit XML. Generation failures are errors, files whose package
+// tests failed are failures, and files that weren't written or were canceled are skipped.
+func (r *Report) junit() ([]byte, error) {
+	suite := junitSuite{
+		Name:      "autotest",
+		Tests:     len(r.Entries),
+		Time:      seconds(r.DurationMS),
+		Timestamp: r.Started.Format("2006-01-02T15:04:05"),
+	}
+
+	for _, entry := range r.Entries {
+		tc := junitCase{
+			Name:      entry.Source,
+			Classname: "autotest." + entry.Package,
+			Time:      seconds(entry.DurationMS),
+			SystemOut: fmt.Sprintf("test=%s provider=%s attempts=%d promptTokens=%d completionTokens=%d cost=%.4f outcome=%s", entry.Test, entry.Provider, entry.Attempts, entry.PromptTokens, entry.CompletionTokens, entry.Cost, entry.Outcome),
+		}
+
+		switch {
+		case entry.Failed():
+			tc.Error = &junitMessage{Message: entry.Error, Type: string(entry.ErrorCategory), Text: entry.Error}
+			suite.Errors++
+		case entry.Tests == TestsFailed:
+			tc.Failure = &junitMessage{Message: "generated tests failed", Type: "test", Text: entry.Test}
+			suite.Failures++
+		case entry.Outcome == "skipped" || entry.Outcome == "rejected" || entry.Outcome == OutcomeCanceled:
+			tc.Skipped = &junitMessage{Message: entry.Outcome}
+			suite.Skipped++
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	content, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+// seconds formats milliseconds as JUnit's decimal seconds.
+func seconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
@@ -0,0 +1,204 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Format is a report file format.
+type Format string
+
+const (
+	// JSON is autotest's own report format.
+	JSON Format = "json"
+	// JUnit is JUnit XML, with one test case per source file.
+	JUnit Format = "junit"
+	// SARIF is SARIF 2.1.0, with one result per failed file.
+	SARIF Format = "sarif"
+)
+
+// ParseFormat validates a report format name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case JSON, JUnit, SARIF:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid report format: %s (must be json, junit, or sarif)", name)
+	}
+}
+
+// Category classifies why a file failed.
+type Category string
+
+const (
+	// CategoryProvider is a failure of the AI provider to generate tests.
+	CategoryProvider Category = "provider"
+	// CategoryMerge is a failure to merge generated tests into an existing test file.
+	CategoryMerge Category = "merge"
+	// CategoryInvalid means the generated tests failed validation.
+	CategoryInvalid Category = "invalid"
+	// CategoryWrite is a failure to write the test file.
+	CategoryWrite Category = "write"
+	// CategoryInternal is any other failure.
+	CategoryInternal Category = "internal"
+	// CategoryCanceled means the run was interrupted or timed out before the file was done.
+	CategoryCanceled Category = "canceled"
+)
+
+// Error is an error with the Category it is reported under.
+type Error struct {
+	Category Category
+	Err      error
+}
+
+// Error returns the message of the wrapped error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// CategoryOf returns the category of err, or CategoryInternal if it has none.
+func CategoryOf(err error) Category {
+	var categorized *Error
+	if errors.As(err, &categorized) {
+		return categorized.Category
+	}
+	return CategoryInternal
+}
+
+// Outcomes of a file besides the output.Outcome names.
+const (
+	// OutcomeFailed means no test code was generated.
+	OutcomeFailed = "failed"
+	// OutcomePlanned means tests were generated in a dry run and not written.
+	OutcomePlanned = "planned"
+	// OutcomeCanceled means the run stopped before tests were generated.
+	OutcomeCanceled = "canceled"
+)
+
+// Test run results of an entry.
+const (
+	TestsPassed = "passed"
+	TestsFailed = "failed"
+)
+
+// Entry is the report of a single source file.
+type Entry struct {
+	Source     string `json:"source"`
+	Test       string `json:"test"`
+	Package    string `json:"package,omitempty"`
+	Provider   string `json:"provider"`
+	Attempts   int    `json:"attempts"`
+	Cached     bool   `json:"cached,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+	// PromptTokens and CompletionTokens are the tokens of all provider calls for the file,
+	// estimated if TokensEstimated is set.
+	PromptTokens     int  `json:"promptTokens"`
+	CompletionTokens int  `json:"completionTokens"`
+	TokensEstimated  bool `json:"tokensEstimated,omitempty"`
+	// Cost is the price of the tokens in US dollars, counting only models with a price.
+	Cost float64 `json:"cost"`
+	// Outcome is created, updated, unchanged, skipped, rejected, planned, canceled, or failed.
+	Outcome       string   `json:"outcome"`
+	ErrorCategory Category `json:"errorCategory,omitempty"`
+	Error         string   `json:"error,omitempty"`
+	// Tests is the result of the test run of the file's package: passed, failed, or empty if
+	// the tests were not run.
+	Tests string `json:"tests,omitempty"`
+	// Coverage is the coverage of the file's package, if it was measured.
+	Coverage *float64 `json:"coverage,omitempty"`
+}
+
+// Failed reports whether generating or writing the file failed. Canceled files have an
+// error too, but didn't fail.
+func (e Entry) Failed() bool {
+	return e.Outcome == OutcomeFailed
+}
+
+// Summary counts the entries of a report and totals their usage.
+type Summary struct {
+	Files            int     `json:"files"`
+	Written          int     `json:"written"`
+	Failed           int     `json:"failed"`
+	Canceled         int     `json:"canceled"`
+	TestsFailed      int     `json:"testsFailed"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Report is the outcome of a generation run.
+type Report struct {
+	Root       string    `json:"root"`
+	Started    time.Time `json:"started"`
+	DurationMS int64     `json:"durationMs"`
+	Summary    Summary   `json:"summary"`
+	Entries    []Entry   `json:"entries"`
+}
+
+// New returns an empty report of a run starting now.
+func New(root string) *Report {
+	return &Report{Root: root, Started: time.Now(), Entries: []Entry{}}
+}
+
+// Add appends an entry.
+func (r *Report) Add(entry Entry) {
+	r.Entries = append(r.Entries, entry)
+}
+
+// Finish sets the run duration and the summary.
+func (r *Report) Finish() {
+	r.DurationMS = time.Since(r.Started).Milliseconds()
+	r.Summary = Summary{Files: len(r.Entries)}
+	for _, entry := range r.Entries {
+		switch {
+		case entry.Failed():
+			r.Summary.Failed++
+		case entry.Outcome == OutcomeCanceled:
+			r.Summary.Canceled++
+		case entry.Outcome == "created" || entry.Outcome == "updated":
+			r.Summary.Written++
+		}
+		if entry.Tests == TestsFailed {
+			r.Summary.TestsFailed++
+		}
+		r.Summary.PromptTokens += entry.PromptTokens
+		r.Summary.CompletionTokens += entry.CompletionTokens
+		r.Summary.Cost += entry.Cost
+	}
+}
+
+// Write finishes the report and writes it to path in the given format.
+func (r *Report) Write(path string, format Format) error {
+	r.Finish()
+
+	var content []byte
+	var err error
+	switch format {
+	case JUnit:
+		content, err = r.junit()
+	case SARIF:
+		content, err = r.sarif()
+	default:
+		content, err = json.MarshalIndent(r, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode %s report: %w", format, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package report
+
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
+// sarifSchema is the JSON schema of SARIF 2.1.0.
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifRules describes the rule of every result kind.
+var sarifRules = []sarifRule{
+	{ID: "autotest/" + string(CategoryProvider), ShortDescription: sarifMessage{Text: "The AI provider failed to generate tests"}},
+	{ID: "autotest/" + string(CategoryMerge), ShortDescription: sarifMessage{Text: "Generated tests could not be merged into the existing test file"}},
+	{ID: "autotest/" + string(CategoryInvalid), ShortDescription: sarifMessage{Text: "The generated tests failed validation"}},
+	{ID: "autotest/" + string(CategoryWrite), ShortDescription: sarifMessage{Text: "The test file could not be written"}},
+	{ID: "autotest/" + string(CategoryInternal), ShortDescription: sarifMessage{Text: "Test generation failed"}},
+	{ID: "autotest/test", ShortDescription: sarifMessage{Text: "Generated tests failed"}},
+}
+
+// sarif renders the report as SARIF 2.1.0 with a result for every failed file. Generation
+// failures are errors on the source file; failing generated tests are warnings on the test file.
+func (r *Report) sarif() ([]byte, error) {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "autotest",
+			InformationURI: "https://github.com/tanerincode/auto-test-generator",
+			Rules:          sarifRules,
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, entry := range r.Entries {
+		properties := map[string]interface{}{
+			"provider":         entry.Provider,
+			"attempts":         entry.Attempts,
+			"promptTokens":     entry.PromptTokens,
+			"completionTokens": entry.CompletionTokens,
+			"cost":             entry.Cost,
+		}
+
+		switch {
+		case entry.Failed():
+			run.Results = append(run.Results, sarifResult{
+				RuleID:     "autotest/" + string(entry.ErrorCategory),
+				Level:      "error",
+				Message:    sarifMessage{Text: entry.Error},
+				Locations:  sarifLocations(entry.Source),
+				Properties: properties,
+			})
+		case entry.Tests == TestsFailed:
+			run.Results = append(run.Results, sarifResult{
+				RuleID:     "autotest/test",
+				Level:      "warning",
+				Message:    sarifMessage{Text: "generated tests for " + entry.Source + " failed"},
+				Locations:  sarifLocations(entry.Test),
+				Properties: properties,
+			})
+		}
+	}
+
+	return json.MarshalIndent(sarifLog{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}}, "", "  ")
+}
+
+// sarifLocations returns the location of a file, with a forward-slash relative URI.
+func sarifLocations(path string) []sarifLocation {
+	return []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(path)}}}}
+}
@@ -0,0 +1,205 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// CommitToBranch commits the files at paths, as they are on disk, on top of HEAD of the repository
+// containing root, and creates branch at the commit. The index, the working tree and the current
+// branch are left as they are. It returns the hash of the commit.
+func CommitToBranch(root string, branch string, message string, paths []string) (plumbing.Hash, error) {
+	repo, err := openRepo(root)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	name := plumbing.NewBranchReferenceName(branch)
+	if _, err := repo.Reference(name, false); err == nil {
+		return plumbing.ZeroHash, fmt.Errorf("branch %s already exists", branch)
+	}
+	author, err := signature(repo)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to resolve HEAD, which the branch starts from: %w", err)
+	}
+	parent, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+	tree, err := parent.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to read HEAD tree: %w", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	repoRoot, err := filepath.Abs(wt.Filesystem.Root())
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	blobs := make(map[string]plumbing.Hash)
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		rel, err := filepath.Rel(repoRoot, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return plumbing.ZeroHash, fmt.Errorf("%s is outside the repository", path)
+		}
+		content, err := os.ReadFile(abs)
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if blobs[filepath.ToSlash(rel)], err = writeBlob(repo.Storer, content); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	treeHash, err := writeTree(repo.Storer, tree, blobs)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	commit := &object.Commit{
+		Author:       author,
+		Committer:    author,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{parent.Hash},
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to encode commit: %w", err)
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to write commit: %w", err)
+	}
+
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(name, hash)); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+	return hash, nil
+}
+
+// signature returns the author of new commits of repo, from user.name and user.email of the git
+// config.
+func signature(repo *git.Repository) (object.Signature, error) {
+	cfg, err := repo.ConfigScoped(config.GlobalScope)
+	if err != nil {
+		return object.Signature{}, fmt.Errorf("failed to read git config: %w", err)
+	}
+	if cfg.User.Name == "" || cfg.User.Email == "" {
+		return object.Signature{}, errors.New("git user.name and user.email are not set; set them with git config to commit")
+	}
+	return object.Signature{Name: cfg.User.Name, Email: cfg.User.Email, When: time.Now()}, nil
+}
+
+// writeTree writes tree with the blobs at the paths of files, relative to it, added or replaced,
+// and returns the hash of the new tree. A nil tree is empty.
+func writeTree(s storer.EncodedObjectStorer, tree *object.Tree, files map[string]plumbing.Hash) (plumbing.Hash, error) {
+	entries := make(map[string]object.TreeEntry)
+	if tree != nil {
+		for _, e := range tree.Entries {
+			entries[e.Name] = e
+		}
+	}
+
+	subdirs := make(map[string]map[string]plumbing.Hash)
+	for path, hash := range files {
+		dir, rest, nested := strings.Cut(path, "/")
+		if !nested {
+			mode := filemode.Regular
+			if e, ok := entries[dir]; ok && e.Mode == filemode.Executable {
+				mode = e.Mode
+			}
+			entries[dir] = object.TreeEntry{Name: dir, Mode: mode, Hash: hash}
+			continue
+		}
+		if subdirs[dir] == nil {
+			subdirs[dir] = make(map[string]plumbing.Hash)
+		}
+		subdirs[dir][rest] = hash
+	}
+
+	for dir, sub := range subdirs {
+		var subtree *object.Tree
+		if e, ok := entries[dir]; ok && e.Mode == filemode.Dir {
+			var err error
+			if subtree, err = object.GetTree(s, e.Hash); err != nil {
+				return plumbing.ZeroHash, fmt.Errorf("failed to read tree %s: %w", dir, err)
+			}
+		}
+		hash, err := writeTree(s, subtree, sub)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entries[dir] = object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: hash}
+	}
+
+	// Git orders entries by name, comparing directories as if they ended with a slash
+	sortKey := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sorted := &object.Tree{}
+	for _, e := range entries {
+		sorted.Entries = append(sorted.Entries, e)
+	}
+	slices.SortFunc(sorted.Entries, func(a, b object.TreeEntry) int {
+		return strings.Compare(sortKey(a), sortKey(b))
+	})
+
+	obj := s.NewEncodedObject()
+	if err := sorted.Encode(obj); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to encode tree: %w", err)
+	}
+	hash, err := s.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to write tree: %w", err)
+	}
+	return hash, nil
+}
+
+// writeBlob stores content as a blob, and returns its hash.
+func writeBlob(s storer.EncodedObjectStorer, content []byte) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(content); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	hash, err := s.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to write blob: %w", err)
+	}
+	return hash, nil
+}
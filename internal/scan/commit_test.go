@@ -0,0 +1,224 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// initRepo creates a repository in a temporary directory with files committed, and returns it with
+// its directory. The git config of the user is kept out of it.
+func initRepo(t *testing.T, files map[string]string) (*git.Repository, string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	cfg.User.Name = "Test"
+	cfg.User.Email = "test@example.com"
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree: %v", err)
+	}
+	for path, content := range files {
+		writeFile(t, filepath.Join(dir, path), content)
+		if _, err := wt.Add(path); err != nil {
+			t.Fatalf("Add %s: %v", path, err)
+		}
+	}
+	sig := &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	if _, err := wt.Commit("initial", &git.CommitOptions{Author: sig}); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	return repo, dir
+}
+
+// writeFile writes content to path, creating its directory.
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// treeFiles returns the content of every file of the tree of commit, by path.
+func treeFiles(t *testing.T, commit *object.Commit) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	iter, err := commit.Files()
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	err = iter.ForEach(func(f *object.File) error {
+		content, err := f.Contents()
+		files[f.Name] = content
+		return err
+	})
+	if err != nil {
+		t.Fatalf("reading files: %v", err)
+	}
+	return files
+}
+
+func TestCommitToBranch(t *testing.T) {
+	repo, dir := initRepo(t, map[string]string{
+		"README.md":     "readme\n",
+		"src/a.ts":      "export const a = 1;\n",
+		"src/lib/b.ts":  "export const b = 2;\n",
+		"src/a.test.ts": "old test\n",
+	})
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexBefore, err := repo.Storer.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(dir, "README.md"), "edited but not committed\n")
+	writeFile(t, filepath.Join(dir, "src/a.test.ts"), "new test\n")
+	writeFile(t, filepath.Join(dir, "src/lib/b.test.ts"), "test of b\n")
+	writeFile(t, filepath.Join(dir, "tests/c.test.ts"), "test in a new directory\n")
+	paths := []string{
+		filepath.Join(dir, "src/a.test.ts"),
+		filepath.Join(dir, "src/lib/b.test.ts"),
+		filepath.Join(dir, "tests/c.test.ts"),
+	}
+
+	hash, err := CommitToBranch(filepath.Join(dir, "src"), "autotest/1", "Add tests", paths)
+	if err != nil {
+		t.Fatalf("CommitToBranch: %v", err)
+	}
+
+	branch, err := repo.Reference(plumbing.NewBranchReferenceName("autotest/1"), false)
+	if err != nil || branch.Hash() != hash {
+		t.Fatalf("branch autotest/1 = %v (%v), want %s", branch, err, hash)
+	}
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Message != "Add tests" || !reflect.DeepEqual(commit.ParentHashes, []plumbing.Hash{head.Hash()}) {
+		t.Errorf("commit %q has parents %v, want %q on %s", commit.Message, commit.ParentHashes, "Add tests", head.Hash())
+	}
+	if commit.Author.Email != "test@example.com" {
+		t.Errorf("author = %s, want test@example.com", commit.Author.Email)
+	}
+	want := map[string]string{
+		"README.md":         "readme\n",
+		"src/a.ts":          "export const a = 1;\n",
+		"src/lib/b.ts":      "export const b = 2;\n",
+		"src/a.test.ts":     "new test\n",
+		"src/lib/b.test.ts": "test of b\n",
+		"tests/c.test.ts":   "test in a new directory\n",
+	}
+	if got := treeFiles(t, commit); !reflect.DeepEqual(got, want) {
+		t.Errorf("branch files = %v, want %v", got, want)
+	}
+
+	headAfter, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headAfter.Name() != head.Name() || headAfter.Hash() != head.Hash() {
+		t.Errorf("HEAD moved from %s to %s", head, headAfter)
+	}
+	indexAfter, err := repo.Storer.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(indexAfter.Entries, indexBefore.Entries) {
+		t.Error("index changed")
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "README.md"))
+	if err != nil || string(content) != "edited but not committed\n" {
+		t.Errorf("working tree README.md = %q (%v)", content, err)
+	}
+
+	if _, err := CommitToBranch(dir, "autotest/1", "Again", paths); err == nil {
+		t.Error("CommitToBranch overwrote an existing branch")
+	}
+	outside := filepath.Join(t.TempDir(), "x.test.ts")
+	writeFile(t, outside, "x\n")
+	if _, err := CommitToBranch(dir, "autotest/2", "Outside", []string{outside}); err == nil {
+		t.Error("CommitToBranch took a file outside the repository")
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	s := memory.NewStorage()
+	blob, err := writeBlob(s, []byte("x\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := writeBlob(s, []byte("#!/bin/sh\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := &object.Tree{Entries: []object.TreeEntry{
+		{Name: "run.sh", Mode: filemode.Executable, Hash: script},
+		{Name: "keep", Mode: filemode.Regular, Hash: script},
+	}}
+
+	hash, err := writeTree(s, base, map[string]plumbing.Hash{
+		"run.sh":  blob,
+		"a/x":     blob,
+		"a/b/y":   blob,
+		"a.b":     blob,
+		"a-c":     blob,
+		"new.txt": blob,
+	})
+	if err != nil {
+		t.Fatalf("writeTree: %v", err)
+	}
+	tree, err := object.GetTree(s, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Git sorts directories as if their names ended with a slash, so a.b comes before a
+	var names []string
+	for _, e := range tree.Entries {
+		names = append(names, e.Name)
+	}
+	if want := []string{"a-c", "a.b", "a", "keep", "new.txt", "run.sh"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("entries = %q, want %q", names, want)
+	}
+
+	run, err := tree.FindEntry("run.sh")
+	if err != nil || run.Mode != filemode.Executable || run.Hash != blob {
+		t.Errorf("run.sh = %+v (%v), want the new blob, still executable", run, err)
+	}
+	keep, err := tree.FindEntry("keep")
+	if err != nil || keep.Hash != script {
+		t.Errorf("keep = %+v (%v), want it untouched", keep, err)
+	}
+	y, err := tree.FindEntry("a/b/y")
+	if err != nil || y.Hash != blob || y.Mode != filemode.Regular {
+		t.Errorf("a/b/y = %+v (%v)", y, err)
+	}
+}
@@ -1,22 +1,33 @@
 package scan
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/bmatcuk/doublestar/v4"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/tanerincode/auto-test-generator/internal/diff"
+	"github.com/tanerincode/auto-test-generator/internal/framework"
 )
 
-// FindCandidates returns a list of TypeScript/TSX files that don't have corresponding test files.
-func FindCandidates(root string, changedOnly bool) ([]string, error) {
+// FindCandidates returns a list of TypeScript/TSX files that don't have corresponding test files
+// according to the given placement, applied relative to each file's workspace package.
+// With changes, only the changed files are considered. With withTests, files that already have a
+// test file are kept too (for augmenting them).
+func FindCandidates(root string, changes *ChangeSet, ws *Workspace, placement Placement, withTests bool) ([]string, error) {
 	var candidates []string
 
-	if changedOnly {
-		changed, err := ChangedFiles(root)
+	if changes != nil {
+		changed, err := ChangedFiles(root, *changes)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get changed files: %w", err)
 		}
@@ -32,7 +43,7 @@ func FindCandidates(root string, changedOnly bool) ([]string, error) {
 	// Filter: keep only files without tests
 	var result []string
 	for _, candidate := range candidates {
-		if HasTest(candidate) {
+		if !withTests && placement.ForPackage(ws.PackageFor(candidate)).HasTest(candidate) {
 			continue
 		}
 		result = append(result, candidate)
@@ -51,122 +62,555 @@ func AllTypeScriptFiles(root string) ([]string, error) {
 
 	var result []string
 	for _, match := range matches {
-		// Skip node_modules
-		if strings.Contains(match, "node_modules") {
-			continue
-		}
-		// Skip .d.ts files
-		if strings.HasSuffix(match, ".d.ts") {
-			continue
+		if isSource(match) {
+			result = append(result, match)
 		}
-		// Skip test files
-		if strings.Contains(match, ".test.") || strings.Contains(match, ".spec.") {
-			continue
+	}
+
+	return result, nil
+}
+
+// isSource reports whether path is a TypeScript/TSX source file that can get tests.
+func isSource(path string) bool {
+	if !strings.HasSuffix(path, ".ts") && !strings.HasSuffix(path, ".tsx") {
+		return false
+	}
+	// Skip node_modules
+	if strings.Contains(path, "node_modules") {
+		return false
+	}
+	// Skip .d.ts files
+	if strings.HasSuffix(path, ".d.ts") {
+		return false
+	}
+	// Skip test files
+	if strings.Contains(path, ".test.") || strings.Contains(path, ".spec.") {
+		return false
+	}
+	// Skip build/dist
+	if strings.Contains(path, "/build/") || strings.Contains(path, "/dist/") {
+		return false
+	}
+	return true
+}
+
+// Test placement strategies understood by Placement.
+const (
+	PlacementColocated = "colocated"
+	PlacementTestsDir  = "__tests__"
+	PlacementMirror    = "mirror"
+	PlacementTemplate  = "template"
+)
+
+// Placement decides where the test file for a source file lives.
+//
+// Templates may use the placeholders {dir} (source directory relative to the
+// project root), {name} (source file name without extension) and {fw} (the
+// framework's test suffix, "test" or "spec"). The expanded template is
+// resolved against the project root, e.g. "{dir}/__tests__/{name}.{fw}.ts".
+// In a workspace, ForPackage makes each package the project root.
+type Placement struct {
+	Strategy string
+	Root     string // project root
+	TestRoot string // mirror only; relative to Root, the package directory after ForPackage, unless absolute
+	Template string // template only
+}
+
+// NewPlacement validates the strategy and its options and returns a Placement.
+func NewPlacement(strategy, root, testRoot, template string) (Placement, error) {
+	switch strategy {
+	case PlacementColocated, PlacementTestsDir:
+	case PlacementMirror:
+		if testRoot == "" {
+			return Placement{}, fmt.Errorf("placement %q requires a test root (-out)", strategy)
 		}
-		// Skip build/dist
-		if strings.Contains(match, "/build/") || strings.Contains(match, "/dist/") {
-			continue
+	case PlacementTemplate:
+		if !strings.Contains(template, "{name}") {
+			return Placement{}, fmt.Errorf("placement %q requires a template containing {name}", strategy)
 		}
-		result = append(result, match)
+	default:
+		return Placement{}, fmt.Errorf("invalid placement: %s (must be colocated, __tests__, mirror, or template)", strategy)
 	}
 
-	return result, nil
+	return Placement{
+		Strategy: strategy,
+		Root:     root,
+		TestRoot: testRoot,
+		Template: template,
+	}, nil
 }
 
-// HasTest checks if a TypeScript file has a corresponding test file.
-func HasTest(tsPath string) bool {
-	base := strings.TrimSuffix(tsPath, filepath.Ext(tsPath))
-	testPaths := []string{
-		base + ".test.ts",
-		base + ".test.tsx",
-		base + ".spec.ts",
-		base + ".spec.tsx",
+// ForPackage returns the placement rooted at the given workspace package, so its tests stay in
+// the package whose test runner runs them: a relative mirror test root or template is resolved
+// against the package directory. An absolute mirror test root is shared by the packages, so it
+// gets a directory per package, mirroring the package's place in the project.
+func (p Placement) ForPackage(pkg Package) Placement {
+	if p.Strategy == PlacementMirror && filepath.IsAbs(p.TestRoot) {
+		if rel, err := filepath.Rel(p.Root, pkg.Dir); err == nil && !strings.HasPrefix(rel, "..") {
+			p.TestRoot = filepath.Join(p.TestRoot, rel)
+		}
 	}
+	p.Root = pkg.Dir
+	return p
+}
 
-	for _, testPath := range testPaths {
-		if _, err := os.Stat(testPath); err == nil {
-			return true
+// HasTest checks if a TypeScript file has a corresponding test file where this placement puts it.
+func (p Placement) HasTest(tsPath string) bool {
+	_, ok := p.ExistingTest(tsPath)
+	return ok
+}
+
+// ExistingTest returns the path of the test file this placement finds for a TypeScript file.
+func (p Placement) ExistingTest(tsPath string) (string, bool) {
+	for _, suffix := range []string{"test", "spec"} {
+		for _, ext := range []string{".ts", ".tsx"} {
+			testPath := p.path(tsPath, suffix, ext)
+			if _, err := os.Stat(testPath); err == nil {
+				return testPath, true
+			}
 		}
 	}
 
-	return false
+	return "", false
 }
 
-// DefaultTestPath returns the default test file path for a given source file.
-// If outDir is provided, mirrors the structure under outDir; otherwise places next to source.
-func DefaultTestPath(tsPath string, framework string, outDir string) string {
-	ext := ".test.ts"
-	if framework == "vitest" {
-		ext = ".spec.ts"
+// TestPath returns the test file path for a given source file, named after the framework's convention.
+func (p Placement) TestPath(tsPath string, fw framework.Framework) string {
+	return p.path(tsPath, fw.TestSuffix, ".ts")
+}
+
+// path builds the test path for tsPath with the given suffix ("test" or "spec") and extension.
+func (p Placement) path(tsPath string, suffix string, ext string) string {
+	dir := filepath.Dir(tsPath)
+	name := strings.TrimSuffix(filepath.Base(tsPath), filepath.Ext(tsPath))
+	file := name + "." + suffix + ext
+
+	// Mirror and template paths mirror the source's place under the root, which a source outside
+	// it has none of, so its test stays next to it
+	relDir, inside := p.relDir(dir)
+	strategy := p.Strategy
+	if !inside && (strategy == PlacementMirror || strategy == PlacementTemplate) {
+		strategy = PlacementColocated
 	}
 
-	base := strings.TrimSuffix(tsPath, filepath.Ext(tsPath))
+	switch strategy {
+	case PlacementTestsDir:
+		return filepath.Join(dir, "__tests__", file)
+	case PlacementMirror:
+		testRoot := p.TestRoot
+		if !filepath.IsAbs(testRoot) {
+			testRoot = filepath.Join(p.Root, testRoot)
+		}
+		return filepath.Join(testRoot, relDir, file)
+	case PlacementTemplate:
+		expanded := strings.NewReplacer(
+			"{dir}", relDir,
+			"{name}", name,
+			"{fw}", suffix,
+		).Replace(p.Template)
+		if ext != ".ts" && strings.HasSuffix(expanded, ".ts") {
+			expanded = strings.TrimSuffix(expanded, ".ts") + ext
+		}
+		if filepath.IsAbs(expanded) {
+			return filepath.Clean(expanded)
+		}
+		return filepath.Join(p.Root, expanded)
+	default:
+		return filepath.Join(dir, file)
+	}
+}
 
-	if outDir == "" {
-		return base + ext
+// relDir returns dir relative to the project root, and false if it lies outside it.
+func (p Placement) relDir(dir string) (string, bool) {
+	rel, err := filepath.Rel(p.Root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
 	}
+	return rel, true
+}
 
-	// Mirror structure under outDir
-	rel, err := filepath.Rel(".", tsPath)
+// ChangeSet selects the changes ChangedFiles reports.
+type ChangeSet struct {
+	// Base is the branch, tag or commit the current branch is compared against, from the commit
+	// where they diverged. Empty means origin/main, or origin/master.
+	Base string
+	// Staged limits the changes to those staged for the next commit, compared against HEAD.
+	// Otherwise the branch's commits, staged and unstaged changes and untracked files count.
+	Staged bool
+}
+
+// BaseRef returns the branch ChangedFiles compares against by default: origin/main, or
+// origin/master.
+func BaseRef(root string) (string, error) {
+	repo, err := openRepo(root)
 	if err != nil {
-		rel = tsPath
+		return "", err
 	}
-	base = strings.TrimSuffix(rel, filepath.Ext(rel))
-	return filepath.Join(outDir, base+ext)
-}
 
-// ChangedFiles returns TypeScript/TSX files changed against origin/main.
-func ChangedFiles(root string) ([]string, error) {
-	repo, err := git.PlainOpen(root)
+	ref, err := baseRef(repo)
 	if err != nil {
-		return nil, fmt.Errorf("not a git repository: %w", err)
+		return "", err
 	}
+	return ref.Name().Short(), nil
+}
 
+// baseRef finds origin/main, falling back to origin/master.
+func baseRef(repo *git.Repository) (*plumbing.Reference, error) {
 	// Try to get origin/main
 	remoteRef, err := repo.Reference("refs/remotes/origin/main", true)
 	if err != nil {
 		// Fallback to origin/master
 		remoteRef, err = repo.Reference("refs/remotes/origin/master", true)
 		if err != nil {
-			return nil, fmt.Errorf("failed to find origin/main or origin/master: %w", err)
+			return nil, fmt.Errorf("failed to find origin/main or origin/master (use -base to pick another branch): %w", err)
 		}
 	}
+	return remoteRef, nil
+}
 
-	// Use git diff to get changed files
-	cmd := exec.Command("git", "-C", root, "diff", "--name-only", remoteRef.Hash().String()+"...HEAD")
-	output, err := cmd.Output()
+// openRepo opens the git repository containing root.
+func openRepo(root string) (*git.Repository, error) {
+	repo, err := git.PlainOpenWithOptions(root, &git.PlainOpenOptions{DetectDotGit: true})
 	if err != nil {
-		return nil, fmt.Errorf("git diff failed: %w", err)
+		return nil, fmt.Errorf("not a git repository: %w", err)
 	}
+	return repo, nil
+}
 
-	var result []string
-	for _, line := range strings.Split(string(output), "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" {
+// ChangedFiles returns the TypeScript/TSX source files under root that changes selects. Deleted
+// files are left out, and renamed files count under their new name.
+func ChangedFiles(root string, changes ChangeSet) ([]string, error) {
+	files, _, err := changedSources(root, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(files))
+	for path := range files {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// ChangedLines returns the changed lines of the files ChangedFiles returns, keyed by the same
+// paths. Files that are new since the base have nil lines: all of them changed. With
+// changes.Staged, the lines are those of the staged version, so unstaged edits don't count.
+func ChangedLines(root string, changes ChangeSet) (map[string][]diff.Range, error) {
+	files, from, err := changedSources(root, changes)
+	if err != nil {
+		return nil, err
+	}
+	var repo *git.Repository
+	if changes.Staged {
+		if repo, err = openRepo(root); err != nil {
+			return nil, err
+		}
+	}
+
+	lines := make(map[string][]diff.Range, len(files))
+	for path, oldPath := range files {
+		lines[path] = nil
+		file, err := from.File(oldPath)
+		if err != nil {
 			continue
 		}
-		// Only include TypeScript/TSX files
-		if strings.HasSuffix(line, ".ts") || strings.HasSuffix(line, ".tsx") {
-			// Skip .d.ts, test files, node_modules, build/dist
-			if strings.HasSuffix(line, ".d.ts") || strings.Contains(line, ".test.") || strings.Contains(line, ".spec.") {
-				continue
-			}
-			if strings.Contains(line, "node_modules") || strings.Contains(line, "/build/") || strings.Contains(line, "/dist/") {
-				continue
+		oldContent, err := file.Contents()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s at base: %w", oldPath, err)
+		}
+		var newContent string
+		if repo != nil {
+			// Staged paths are the same in HEAD and the index
+			newContent, err = indexContent(repo, oldPath)
+		} else {
+			var content []byte
+			content, err = os.ReadFile(path)
+			newContent = string(content)
+		}
+		if err != nil {
+			return nil, err
+		}
+		lines[path] = diff.ChangedLines(oldContent, newContent)
+	}
+	return lines, nil
+}
+
+// indexContent returns the staged content of the file at path, relative to the repository root.
+func indexContent(repo *git.Repository, path string) (string, error) {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return "", fmt.Errorf("failed to read git index: %w", err)
+	}
+	entry, err := idx.Entry(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to find %s in git index: %w", path, err)
+	}
+	blob, err := repo.BlobObject(entry.Hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to read staged %s: %w", path, err)
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return "", fmt.Errorf("failed to read staged %s: %w", path, err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read staged %s: %w", path, err)
+	}
+	return string(content), nil
+}
+
+// changedSources returns the changed source files under root that exist, as paths joined to
+// root like AllTypeScriptFiles returns, mapped to their path in the base tree, which it also
+// returns.
+func changedSources(root string, changes ChangeSet) (map[string]string, *object.Tree, error) {
+	repo, err := openRepo(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	// Paths are relative to the repository root; staged changes compare against HEAD
+	changed := make(map[string]string)
+	var from *object.Tree
+	if changes.Staged {
+		from, err = headTree(repo)
+	} else {
+		from, err = committedChanges(repo, changes.Base, changed)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	for path, file := range status {
+		if _, ok := changed[path]; ok {
+			continue
+		}
+		switch {
+		case changes.Staged:
+			if file.Staging != git.Unmodified && file.Staging != git.Untracked && file.Staging != git.Deleted {
+				changed[path] = path
 			}
-			fullPath := filepath.Join(root, line)
-			result = append(result, fullPath)
+		case file.Staging != git.Unmodified || file.Worktree != git.Unmodified:
+			changed[path] = path
 		}
 	}
 
-	return result, nil
+	repoRoot, err := filepath.Abs(wt.Filesystem.Root())
+	if err != nil {
+		return nil, nil, err
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := make(map[string]string)
+	for path, oldPath := range changed {
+		if !isSource(path) {
+			continue
+		}
+		// Keep the files under root
+		rel, err := filepath.Rel(absRoot, filepath.Join(repoRoot, filepath.FromSlash(path)))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		fullPath := filepath.Join(root, rel)
+		if _, err := os.Stat(fullPath); err != nil {
+			continue
+		}
+		result[fullPath] = oldPath
+	}
+
+	return result, from, nil
+}
+
+// headTree returns the tree of the HEAD commit.
+func headTree(repo *git.Repository) (*object.Tree, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
+	}
+	return commit.Tree()
+}
+
+// committedChanges adds the files changed on HEAD's branch since it diverged from base to
+// changed, mapped to their name at the merge base, with renames detected. It returns the tree of
+// the merge base.
+func committedChanges(repo *git.Repository, base string, changed map[string]string) (*object.Tree, error) {
+	var baseHash plumbing.Hash
+	if base == "" {
+		ref, err := baseRef(repo)
+		if err != nil {
+			return nil, err
+		}
+		baseHash = ref.Hash()
+	} else {
+		hash, err := repo.ResolveRevision(plumbing.Revision(base))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve base %s: %w", base, err)
+		}
+		baseHash = *hash
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
+	}
+	baseCommit, err := repo.CommitObject(baseHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get base commit: %w", err)
+	}
+
+	// Compare from the merge base, so changes made on the base branch since don't count
+	bases, err := headCommit.MergeBase(baseCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find merge base: %w", err)
+	}
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("HEAD and %s have no common ancestor", baseHash)
+	}
+
+	from, err := bases[0].Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merge base tree: %w", err)
+	}
+	to, err := headCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD tree: %w", err)
+	}
+	changes, err := object.DiffTreeWithOptions(context.Background(), from, to, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff against merge base: %w", err)
+	}
+	for _, change := range changes {
+		// Deletions have no new name
+		if change.To.Name != "" {
+			changed[change.To.Name] = change.From.Name
+		}
+	}
+	return from, nil
+}
+
+// RepoRoot returns the top-level directory of the git repository containing root.
+func RepoRoot(root string) (string, error) {
+	repo, err := git.PlainOpenWithOptions(root, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %w", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	return filepath.Abs(wt.Filesystem.Root())
+}
+
+// HooksDir returns the directory git runs the hooks of the repository containing root from:
+// core.hooksPath if set, or the hooks directory of the git directory.
+func HooksDir(root string) (string, error) {
+	repo, err := openRepo(root)
+	if err != nil {
+		return "", err
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return "", fmt.Errorf("failed to read git config: %w", err)
+	}
+	if hooksPath := cfg.Raw.Section("core").Option("hooksPath"); hooksPath != "" {
+		if filepath.IsAbs(hooksPath) {
+			return hooksPath, nil
+		}
+		// A relative path is relative to the top of the working tree, where hooks run
+		repoRoot, err := RepoRoot(root)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(repoRoot, hooksPath), nil
+	}
+
+	dir, err := gitDir(repo)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hooks"), nil
+}
+
+// gitDir returns the absolute path of the git directory of repo.
+func gitDir(repo *git.Repository) (string, error) {
+	storage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return "", errors.New("git directory not found")
+	}
+	return filepath.Abs(storage.Filesystem().Root())
+}
+
+// Stage adds the files at paths to the git index of the repository containing root, like git add.
+func Stage(root string, paths []string) error {
+	repo, err := openRepo(root)
+	if err != nil {
+		return err
+	}
+
+	// Hooks of git commit -a or git commit <paths> get a temporary index, which go-git can't write
+	if index := os.Getenv("GIT_INDEX_FILE"); index != "" {
+		dir, err := gitDir(repo)
+		if err != nil {
+			return err
+		}
+		if abs, err := filepath.Abs(index); err != nil || abs != filepath.Join(dir, "index") {
+			return errors.New("git is committing from a temporary index (git commit -a or with paths); stage the files with git add")
+		}
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+	repoRoot, err := filepath.Abs(wt.Filesystem.Root())
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(repoRoot, abs)
+		if err != nil {
+			return err
+		}
+		if _, err := wt.Add(filepath.ToSlash(rel)); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", path, err)
+		}
+	}
+	return nil
 }
 
 // IsWorkingTreeDirty checks if the git working tree has uncommitted changes.
 func IsWorkingTreeDirty(root string) (bool, error) {
-	repo, err := git.PlainOpen(root)
+	repo, err := openRepo(root)
 	if err != nil {
-		return false, fmt.Errorf("not a git repository: %w", err)
+		return false, err
 	}
 
 	wt, err := repo.Worktree()
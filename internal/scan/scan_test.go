@@ -0,0 +1,56 @@
+package scan
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/tanerincode/auto-test-generator/internal/framework"
+)
+
+func TestMirrorPlacementForPackage(t *testing.T) {
+	root := t.TempDir()
+	pkg := Package{Name: "a", Dir: filepath.Join(root, "packages", "a")}
+	source := filepath.Join(pkg.Dir, "src", "x.ts")
+	fw := framework.Framework{TestSuffix: "spec"}
+
+	tests := []struct {
+		name     string
+		testRoot string
+		want     string
+	}{
+		{"relative test root is per package", "test", filepath.Join(pkg.Dir, "test", "src", "x.spec.ts")},
+		{"absolute test root mirrors packages", filepath.Join(root, "tests"), filepath.Join(root, "tests", "packages", "a", "src", "x.spec.ts")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPlacement(PlacementMirror, root, tt.testRoot, "")
+			if err != nil {
+				t.Fatalf("NewPlacement: %v", err)
+			}
+			if got := p.ForPackage(pkg).TestPath(source, fw); got != tt.want {
+				t.Errorf("TestPath = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlacementOutsideRootIsColocated(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "lib", "x.ts")
+	fw := framework.Framework{TestSuffix: "spec"}
+	want := filepath.Join(filepath.Dir(outside), "x.spec.ts")
+
+	mirror, err := NewPlacement(PlacementMirror, root, "test", "")
+	if err != nil {
+		t.Fatalf("NewPlacement: %v", err)
+	}
+	template, err := NewPlacement(PlacementTemplate, root, "", "tests/{dir}/{name}.{fw}.ts")
+	if err != nil {
+		t.Fatalf("NewPlacement: %v", err)
+	}
+	for _, p := range []Placement{mirror, template} {
+		if got := p.TestPath(outside, fw); got != want {
+			t.Errorf("%s: TestPath = %s, want %s", p.Strategy, got, want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package scan
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Package is a single package.json-rooted package inside a project.
+type Package struct {
+	Name string
+	Dir  string
+}
+
+// Workspace lists the packages of a project. A project without workspaces
+// is a workspace with a single package at its root.
+type Workspace struct {
+	Root     string
+	Packages []Package
+}
+
+// LoadWorkspace discovers workspace packages from pnpm-workspace.yaml or the
+// "workspaces" field of the root package.json.
+func LoadWorkspace(root string) (*Workspace, error) {
+	patterns, err := workspacePatterns(root)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &Workspace{
+		Root:     root,
+		Packages: []Package{{Name: packageName(root), Dir: filepath.Clean(root)}},
+	}
+
+	var include, exclude []string
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			exclude = append(exclude, strings.TrimPrefix(pattern, "!"))
+		} else {
+			include = append(include, pattern)
+		}
+	}
+
+	seen := map[string]bool{filepath.Clean(root): true}
+	for _, pattern := range include {
+		matches, err := doublestar.FilepathGlob(filepath.Join(root, pattern, "package.json"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace pattern %q: %w", pattern, err)
+		}
+
+		for _, match := range matches {
+			dir := filepath.Dir(match)
+			if seen[dir] || strings.Contains(dir, "node_modules") || isExcluded(root, dir, exclude) {
+				continue
+			}
+			seen[dir] = true
+			ws.Packages = append(ws.Packages, Package{Name: packageName(dir), Dir: dir})
+		}
+	}
+
+	sort.Slice(ws.Packages, func(i, j int) bool {
+		return ws.Packages[i].Dir < ws.Packages[j].Dir
+	})
+
+	return ws, nil
+}
+
+// IsMonorepo reports whether the workspace has packages besides the root.
+func (w *Workspace) IsMonorepo() bool {
+	return len(w.Packages) > 1
+}
+
+// PackageFor returns the package that owns path, i.e. the one with the deepest directory containing it.
+func (w *Workspace) PackageFor(path string) Package {
+	owner := Package{Name: packageName(w.Root), Dir: filepath.Clean(w.Root)}
+	depth := -1
+
+	for _, pkg := range w.Packages {
+		rel, err := filepath.Rel(pkg.Dir, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if d := len(pkg.Dir); d > depth {
+			owner = pkg
+			depth = d
+		}
+	}
+
+	return owner
+}
+
+// Group splits paths by the directory of their owning package.
+func (w *Workspace) Group(paths []string) map[string][]string {
+	groups := make(map[string][]string)
+	for _, path := range paths {
+		pkg := w.PackageFor(path)
+		groups[pkg.Dir] = append(groups[pkg.Dir], path)
+	}
+	return groups
+}
+
+// workspacePatterns returns the package globs declared by the project, if any.
+func workspacePatterns(root string) ([]string, error) {
+	if content, err := os.ReadFile(filepath.Join(root, "pnpm-workspace.yaml")); err == nil {
+		return parsePnpmWorkspace(string(content)), nil
+	}
+
+	content, err := os.ReadFile(filepath.Join(root, "package.json"))
+	if err != nil {
+		return nil, nil
+	}
+
+	var pkg struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+	if len(pkg.Workspaces) == 0 {
+		return nil, nil
+	}
+
+	// "workspaces" is either a list of globs or {"packages": [...]} (yarn classic).
+	var patterns []string
+	if err := json.Unmarshal(pkg.Workspaces, &patterns); err == nil {
+		return patterns, nil
+	}
+	var nested struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(pkg.Workspaces, &nested); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json workspaces: %w", err)
+	}
+	return nested.Packages, nil
+}
+
+// parsePnpmWorkspace extracts the "packages" list from pnpm-workspace.yaml.
+func parsePnpmWorkspace(content string) []string {
+	var patterns []string
+	inPackages := false
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx != -1 {
+			line = line[:idx]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		// A new top-level key ends the packages list
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && !strings.HasPrefix(line, "-") {
+			inPackages = strings.HasPrefix(trimmed, "packages:")
+			continue
+		}
+
+		if inPackages && strings.HasPrefix(trimmed, "-") {
+			pattern := strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			pattern = strings.Trim(pattern, "'\"")
+			if pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+
+	return patterns
+}
+
+// isExcluded reports whether dir matches one of the negated workspace patterns.
+func isExcluded(root string, dir string, exclude []string) bool {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, pattern := range exclude {
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// packageName returns the "name" field of dir/package.json, or the directory name.
+func packageName(dir string) string {
+	content, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err == nil {
+		var pkg struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(content, &pkg) == nil && pkg.Name != "" {
+			return pkg.Name
+		}
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	return filepath.Base(abs)
+}
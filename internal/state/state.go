@@ -0,0 +1,143 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/tanerincode/auto-test-generator/internal/cache"
+	"github.com/tanerincode/auto-test-generator/internal/output"
+)
+
+// FileName is the manifest of generated tests, in cache.Dir.
+const FileName = "state.json"
+
+// version is the manifest format written by this version of autotest.
+const version = 1
+
+// Record is a test file autotest wrote, and the source it was generated from. Paths are relative
+// to the project root.
+type Record struct {
+	Source     string `json:"source"`
+	SourceHash string `json:"sourceHash"`
+	Test       string `json:"test"`
+	// Generator is the provider that generated the tests.
+	Generator string    `json:"generator"`
+	Generated time.Time `json:"generated"`
+}
+
+// State is the manifest of the tests autotest generated in a project, keyed by source path.
+type State struct {
+	Version int               `json:"version"`
+	Files   map[string]Record `json:"files"`
+}
+
+// path returns the manifest file of the project at root.
+func path(root string) string {
+	return filepath.Join(root, cache.Dir, FileName)
+}
+
+// Load reads the manifest of the project at root. A project without one has an empty manifest.
+func Load(root string) (*State, error) {
+	s := &State{Version: version, Files: make(map[string]Record)}
+	content, err := os.ReadFile(path(root))
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+	if err := json.Unmarshal(content, s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", FileName, err)
+	}
+	if s.Files == nil {
+		s.Files = make(map[string]Record)
+	}
+	return s, nil
+}
+
+// Save writes the manifest of the project at root.
+func (s *State) Save(root string) error {
+	s.Version = version
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", FileName, err)
+	}
+	if err := cache.EnsureDir(root); err != nil {
+		return err
+	}
+	if err := output.WriteFileAtomic(path(root), append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", FileName, err)
+	}
+	return nil
+}
+
+// Add records rec, replacing any earlier record of its source.
+func (s *State) Add(rec Record) {
+	s.Files[rec.Source] = rec
+}
+
+// Remove drops the record of source.
+func (s *State) Remove(source string) {
+	delete(s.Files, source)
+}
+
+// Hash returns the hash a Record keeps of source content.
+func Hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Status is how a recorded test relates to its source now.
+type Status string
+
+const (
+	// Fresh means the source is unchanged since its tests were generated.
+	Fresh Status = "fresh"
+	// Changed means the source changed since its tests were generated.
+	Changed Status = "changed"
+	// SourceDeleted means the source no longer exists, so its tests may be orphaned.
+	SourceDeleted Status = "source-deleted"
+	// TestDeleted means the generated test file no longer exists.
+	TestDeleted Status = "test-deleted"
+)
+
+// Check is the status of one record.
+type Check struct {
+	Record
+	Status Status `json:"status"`
+}
+
+// Check returns the status of every record in the project at root, sorted by source path.
+func (s *State) Check(root string) []Check {
+	checks := make([]Check, 0, len(s.Files))
+	for _, rec := range s.Files {
+		checks = append(checks, Check{Record: rec, Status: status(root, rec)})
+	}
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].Source < checks[j].Source
+	})
+	return checks
+}
+
+// status returns the status of rec in the project at root.
+func status(root string, rec Record) Status {
+	content, err := os.ReadFile(filepath.Join(root, rec.Source))
+	if err != nil {
+		return SourceDeleted
+	}
+	if _, err := os.Stat(filepath.Join(root, rec.Test)); err != nil {
+		return TestDeleted
+	}
+	if Hash(string(content)) != rec.SourceHash {
+		return Changed
+	}
+	return Fresh
+}